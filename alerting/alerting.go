@@ -0,0 +1,261 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package alerting
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+//Alert states
+const (
+	StatePending  = "pending"
+	StateFiring   = "firing"
+	StateResolved = "resolved"
+
+	defaultSeverity   = "warning"
+	resolvedRetention = time.Hour
+)
+
+var comparators = map[string]func(value float64, threshold float64) bool{
+	">":  func(value float64, threshold float64) bool { return value > threshold },
+	">=": func(value float64, threshold float64) bool { return value >= threshold },
+	"<":  func(value float64, threshold float64) bool { return value < threshold },
+	"<=": func(value float64, threshold float64) bool { return value <= threshold },
+	"==": func(value float64, threshold float64) bool { return value == threshold },
+	"!=": func(value float64, threshold float64) bool { return value != threshold },
+}
+
+//Instance is the latest metrics of a single instance of an origin
+type Instance struct {
+	Origin     string
+	Deployment string
+	Job        string
+	Index      string
+	IP         string
+	Metrics    map[string]float64
+}
+
+//Alert is the state of one rule for one instance
+type Alert struct {
+	Rule        string
+	Severity    string
+	State       string
+	Origin      string
+	Deployment  string
+	Job         string
+	Index       string
+	IP          string
+	Metric      string
+	Comparator  string
+	Threshold   float64
+	Value       float64
+	ActiveSince time.Time
+	FiredAt     *time.Time `json:",omitempty"`
+	ResolvedAt  *time.Time `json:",omitempty"`
+
+	lastSeen time.Time
+}
+
+type rule struct {
+	nozzleconfiguration.AlertRuleConfiguration
+	compare func(value float64, threshold float64) bool
+	forTime time.Duration
+}
+
+//Engine evaluates threshold rules and tracks the resulting alerts
+type Engine struct {
+	rules      []rule
+	staleAfter time.Duration
+	notifier   *notifier
+	logger     *gosteno.Logger
+
+	mutex  sync.Mutex
+	alerts map[string]*Alert
+}
+
+//New creates an Engine, alerts of instances that have not reported a metric for staleAfter are resolved
+func New(config *nozzleconfiguration.AlertingConfiguration, staleAfter time.Duration, logger *gosteno.Logger) (*Engine, error) {
+	engine := &Engine{
+		staleAfter: staleAfter,
+		logger:     logger,
+		alerts:     make(map[string]*Alert),
+	}
+
+	//Alert state is kept per rule name, so two rules with one name would overwrite each other's state
+	names := make(map[string]bool, len(config.Rules))
+	for _, ruleConfig := range config.Rules {
+		if ruleConfig.Origin == "" || ruleConfig.Metric == "" {
+			return nil, fmt.Errorf("Alert rule %s requires an origin and a metric", ruleConfig.Name)
+		}
+
+		compare, ok := comparators[ruleConfig.Comparator]
+		if !ok {
+			return nil, fmt.Errorf("Alert rule %s has unknown comparator %s", ruleConfig.Name, ruleConfig.Comparator)
+		}
+
+		if ruleConfig.Name == "" {
+			ruleConfig.Name = fmt.Sprintf("%s.%s", ruleConfig.Origin, ruleConfig.Metric)
+		}
+
+		if names[ruleConfig.Name] {
+			return nil, fmt.Errorf("Alert rule %s is configured twice, rules on the same metric need distinct names", ruleConfig.Name)
+		}
+		names[ruleConfig.Name] = true
+
+		if ruleConfig.Severity == "" {
+			ruleConfig.Severity = defaultSeverity
+		}
+
+		engine.rules = append(engine.rules, rule{
+			AlertRuleConfiguration: ruleConfig,
+			compare:                compare,
+			forTime:                time.Duration(ruleConfig.ForSeconds) * time.Second,
+		})
+	}
+
+	engine.notifier = newNotifier(config.WebhookURLs, logger)
+	return engine, nil
+}
+
+//Origins returns the origins referenced by rules, only instances of these origins need to be evaluated
+func (engine *Engine) Origins() []string {
+	seen := make(map[string]bool)
+	var origins []string
+	for _, rule := range engine.rules {
+		if !seen[rule.Origin] {
+			seen[rule.Origin] = true
+			origins = append(origins, rule.Origin)
+		}
+	}
+
+	return origins
+}
+
+//Evaluate applies every rule to the instances and notifies webhooks of firing and resolved alerts
+func (engine *Engine) Evaluate(instances []Instance, now time.Time) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	seen := make(map[string]bool)
+	for _, rule := range engine.rules {
+		for _, instance := range instances {
+			if instance.Origin != rule.Origin || (rule.Job != "" && instance.Job != rule.Job) {
+				continue
+			}
+
+			value, ok := instance.Metrics[rule.Metric]
+			if !ok {
+				continue
+			}
+
+			key := alertKey(rule.Name, instance.Deployment, instance.Job, instance.Index, instance.IP)
+			seen[key] = true
+			engine.evaluateInstance(key, rule, instance, value, now)
+		}
+	}
+
+	for key, alert := range engine.alerts {
+		switch {
+		case alert.State == StateResolved && now.Sub(*alert.ResolvedAt) > resolvedRetention:
+			delete(engine.alerts, key)
+		case !seen[key] && alert.State != StateResolved && now.Sub(alert.lastSeen) > engine.staleAfter:
+			engine.logger.Debugf("Alert %s has no data since %v", key, alert.lastSeen)
+			engine.clear(key, alert, now)
+		}
+	}
+}
+
+func (engine *Engine) evaluateInstance(key string, rule rule, instance Instance, value float64, now time.Time) {
+	alert, exists := engine.alerts[key]
+
+	if !rule.compare(value, rule.Threshold) {
+		if exists {
+			alert.Value = value
+			alert.lastSeen = now
+			engine.clear(key, alert, now)
+		}
+		return
+	}
+
+	if !exists || alert.State == StateResolved {
+		alert = &Alert{
+			Rule:        rule.Name,
+			Severity:    rule.Severity,
+			State:       StatePending,
+			Origin:      instance.Origin,
+			Deployment:  instance.Deployment,
+			Job:         instance.Job,
+			Index:       instance.Index,
+			IP:          instance.IP,
+			Metric:      rule.Metric,
+			Comparator:  rule.Comparator,
+			Threshold:   rule.Threshold,
+			ActiveSince: now,
+		}
+		engine.alerts[key] = alert
+	}
+
+	alert.Value = value
+	alert.lastSeen = now
+
+	if alert.State == StatePending && now.Sub(alert.ActiveSince) >= rule.forTime {
+		firedAt := now
+		alert.State = StateFiring
+		alert.FiredAt = &firedAt
+		engine.logger.Infof("Alert %s firing with value %v", key, value)
+		engine.notifier.notify(*alert)
+	}
+}
+
+//clear drops pending alerts and resolves firing alerts
+func (engine *Engine) clear(key string, alert *Alert, now time.Time) {
+	switch alert.State {
+	case StatePending:
+		delete(engine.alerts, key)
+	case StateFiring:
+		resolvedAt := now
+		alert.State = StateResolved
+		alert.ResolvedAt = &resolvedAt
+		engine.logger.Infof("Alert %s resolved", key)
+		engine.notifier.notify(*alert)
+	}
+}
+
+//Alerts returns pending, firing and recently resolved alerts ordered by rule
+func (engine *Engine) Alerts() []Alert {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	alerts := make([]Alert, 0, len(engine.alerts))
+	for _, alert := range engine.alerts {
+		alerts = append(alerts, *alert)
+	}
+
+	sort.Sort(byRule(alerts))
+	return alerts
+}
+
+//Close waits for queued notifications to be sent
+func (engine *Engine) Close() {
+	engine.notifier.close()
+}
+
+type byRule []Alert
+
+func (alerts byRule) Len() int      { return len(alerts) }
+func (alerts byRule) Swap(i, j int) { alerts[i], alerts[j] = alerts[j], alerts[i] }
+func (alerts byRule) Less(i, j int) bool {
+	return alertKey(alerts[i].Rule, alerts[i].Deployment, alerts[i].Job, alerts[i].Index, alerts[i].IP) <
+		alertKey(alerts[j].Rule, alerts[j].Deployment, alerts[j].Job, alerts[j].Index, alerts[j].IP)
+}
+
+func alertKey(rule string, deployment string, job string, index string, ip string) string {
+	return fmt.Sprintf("%s | %s | %s | %s | %s", rule, deployment, job, index, ip)
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	alertingLogFile     = "bm_alerting.log"
+	alertingLogName     = "bm_alerting"
+	alertingLogLevel    = "debug"
+
+	testMetric     = "CapacityRemainingMemory"
+	testStaleAfter = 2 * time.Minute
+)
+
+var testRule = nozzleconfiguration.AlertRuleConfiguration{
+	Name:       "LowMemory",
+	Origin:     "rep",
+	Metric:     testMetric,
+	Comparator: "<",
+	Threshold:  1024,
+	ForSeconds: 60,
+	Severity:   "critical",
+}
+
+func TestFiringAndResolving(t *testing.T) {
+	notifications := make(chan Alert, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var alert Alert
+		json.NewDecoder(r.Body).Decode(&alert)
+		notifications <- alert
+	}))
+	defer webhook.Close()
+
+	engine := createEngine(t, &nozzleconfiguration.AlertingConfiguration{
+		WebhookURLs: []string{webhook.URL},
+		Rules:       []nozzleconfiguration.AlertRuleConfiguration{testRule},
+	})
+	start := time.Now()
+
+	t.Log("Evaluating instance below threshold...")
+	engine.Evaluate(createInstances(512), start)
+	checkState(t, engine, StatePending)
+
+	t.Log("Evaluating instance below threshold before the rule duration has passed...")
+	engine.Evaluate(createInstances(256), start.Add(30*time.Second))
+	checkState(t, engine, StatePending)
+
+	t.Log("Evaluating instance below threshold after the rule duration has passed...")
+	engine.Evaluate(createInstances(128), start.Add(time.Minute))
+	checkState(t, engine, StateFiring)
+
+	t.Log("Evaluating instance above threshold...")
+	engine.Evaluate(createInstances(2048), start.Add(2*time.Minute))
+	checkState(t, engine, StateResolved)
+
+	engine.Close()
+
+	for _, expectedState := range []string{StateFiring, StateResolved} {
+		t.Logf("Checking webhook notification... (expected value: %s)", expectedState)
+		alert := <-notifications
+		if alert.State != expectedState || alert.Rule != testRule.Name || alert.Severity != testRule.Severity {
+			t.Errorf("Expected %s notification for %s, but received %+v", expectedState, testRule.Name, alert)
+		}
+	}
+}
+
+func TestPendingCleared(t *testing.T) {
+	engine := createEngine(t, &nozzleconfiguration.AlertingConfiguration{
+		Rules: []nozzleconfiguration.AlertRuleConfiguration{testRule},
+	})
+	start := time.Now()
+
+	t.Log("Evaluating instance below and then above threshold...")
+	engine.Evaluate(createInstances(512), start)
+	engine.Evaluate(createInstances(2048), start.Add(30*time.Second))
+
+	t.Log("Checking pending alert was dropped... (expected value: 0)")
+	if alerts := engine.Alerts(); len(alerts) != 0 {
+		t.Errorf("Expected no alerts, but received %v", alerts)
+	}
+}
+
+func TestStaleAlertResolved(t *testing.T) {
+	rule := testRule
+	rule.ForSeconds = 0
+	engine := createEngine(t, &nozzleconfiguration.AlertingConfiguration{
+		Rules: []nozzleconfiguration.AlertRuleConfiguration{rule},
+	})
+	start := time.Now()
+
+	t.Log("Evaluating instance below threshold without a rule duration...")
+	engine.Evaluate(createInstances(512), start)
+	checkState(t, engine, StateFiring)
+
+	t.Log("Evaluating without data before the alert becomes stale...")
+	engine.Evaluate(nil, start.Add(time.Minute))
+	checkState(t, engine, StateFiring)
+
+	t.Log("Evaluating without data after the alert becomes stale...")
+	engine.Evaluate(nil, start.Add(testStaleAfter+time.Minute))
+	checkState(t, engine, StateResolved)
+}
+
+func TestInvalidRule(t *testing.T) {
+	rule := testRule
+	rule.Comparator = "=>"
+
+	t.Log("Creating engine with unknown comparator... (expecting error)")
+	_, err := New(&nozzleconfiguration.AlertingConfiguration{
+		Rules: []nozzleconfiguration.AlertRuleConfiguration{rule},
+	}, testStaleAfter, createLogger())
+
+	if err == nil {
+		t.Errorf("Expected error creating engine with comparator %s, but engine was created", rule.Comparator)
+	}
+}
+
+func TestDuplicateRuleNames(t *testing.T) {
+	warning := testRule
+	warning.Name = ""
+	critical := warning
+	critical.Threshold = warning.Threshold / 2
+
+	t.Log("Creating engine with two rules defaulting to the same name... (expecting error)")
+	_, err := New(&nozzleconfiguration.AlertingConfiguration{
+		Rules: []nozzleconfiguration.AlertRuleConfiguration{warning, critical},
+	}, testStaleAfter, createLogger())
+
+	if err == nil {
+		t.Error("Expected error creating engine with duplicate rule names, but engine was created")
+	}
+
+	critical.Name = "critical"
+
+	t.Log("Creating engine with distinct rule names on the same metric... (expecting no error)")
+	_, err = New(&nozzleconfiguration.AlertingConfiguration{
+		Rules: []nozzleconfiguration.AlertRuleConfiguration{warning, critical},
+	}, testStaleAfter, createLogger())
+
+	if err != nil {
+		t.Errorf("Expected engine to be created, but received %s", err.Error())
+	}
+}
+
+func checkState(t *testing.T, engine *Engine, expectedState string) {
+	alerts := engine.Alerts()
+
+	t.Logf("Checking alert state... (expected value: %s)", expectedState)
+	if len(alerts) != 1 {
+		t.Fatalf("Expected 1 alert, but received %d", len(alerts))
+	}
+
+	if alerts[0].State != expectedState {
+		t.Errorf("Expected state %s, but received %s", expectedState, alerts[0].State)
+	}
+}
+
+func createEngine(t *testing.T, config *nozzleconfiguration.AlertingConfiguration) *Engine {
+	engine, err := New(config, testStaleAfter, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating alerting engine: %s", err.Error())
+	}
+
+	return engine
+}
+
+func createLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, alertingLogFile, alertingLogName, alertingLogLevel)
+}
+
+func createInstances(value float64) []Instance {
+	return []Instance{{
+		Origin:     "rep",
+		Deployment: "cf",
+		Job:        "cell",
+		Index:      "0",
+		IP:         "10.0.0.1",
+		Metrics:    map[string]float64{testMetric: value},
+	}}
+}
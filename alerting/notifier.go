@@ -0,0 +1,93 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package alerting
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	notificationTimeout   = 10 * time.Second
+	maxQueuedNotification = 100
+)
+
+//notifier posts alert transitions as JSON to every webhook without blocking evaluation
+type notifier struct {
+	urls          []string
+	client        *http.Client
+	logger        *gosteno.Logger
+	notifications chan Alert
+	done          chan struct{}
+	closeOnce     sync.Once
+}
+
+func newNotifier(urls []string, logger *gosteno.Logger) *notifier {
+	n := &notifier{
+		urls:          urls,
+		client:        &http.Client{Timeout: notificationTimeout},
+		logger:        logger,
+		notifications: make(chan Alert, maxQueuedNotification),
+		done:          make(chan struct{}),
+	}
+
+	go n.run()
+	return n
+}
+
+func (n *notifier) notify(alert Alert) {
+	if len(n.urls) == 0 {
+		return
+	}
+
+	select {
+	case n.notifications <- alert:
+	default:
+		n.logger.Warnf("Dropped %s notification for alert %s as webhooks are falling behind", alert.State, alert.Rule)
+	}
+}
+
+func (n *notifier) close() {
+	n.closeOnce.Do(func() {
+		close(n.notifications)
+	})
+	<-n.done
+}
+
+func (n *notifier) run() {
+	defer close(n.done)
+
+	for alert := range n.notifications {
+		body, err := json.Marshal(alert)
+		if err != nil {
+			n.logger.Errorf("Error encoding alert %s: %s", alert.Rule, err.Error())
+			continue
+		}
+
+		for _, url := range n.urls {
+			n.post(url, body)
+		}
+	}
+}
+
+func (n *notifier) post(url string, body []byte) {
+	response, err := n.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		n.logger.Errorf("Error sending alert notification to %s: %s", url, err.Error())
+		return
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		n.logger.Errorf("Alert notification to %s returned status code %d", url, response.StatusCode)
+	}
+}
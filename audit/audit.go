@@ -0,0 +1,176 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+//Audit event types
+const (
+	EventTokenIssued  = "token_issued"
+	EventTokenRevoked = "token_revoked"
+	EventRequest      = "request"
+	EventDenied       = "denied"
+
+	//StdoutOutput writes audit events to stdout instead of a file
+	StdoutOutput = "stdout"
+
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 5
+)
+
+//Event is a single audited API call
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"event"`
+	ClientIP  string    `json:"client_ip"`
+	User      string    `json:"user,omitempty"`
+	TokenID   string    `json:"token_id,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS float64   `json:"latency_ms"`
+}
+
+//Logger writes audit events as JSON lines, files are rotated once they reach their maximum size
+type Logger struct {
+	mutex      sync.Mutex
+	writer     io.Writer
+	file       *os.File
+	path       string
+	maxBytes   int64
+	maxBackups int
+	size       int64
+}
+
+//New creates a Logger writing to stdout or the file at config.Output
+func New(config *nozzleconfiguration.AuditConfiguration) (*Logger, error) {
+	if config.Output == "" || config.Output == StdoutOutput {
+		return NewWithWriter(os.Stdout), nil
+	}
+
+	if config.MaxSizeMB <= 0 {
+		config.MaxSizeMB = defaultMaxSizeMB
+	}
+
+	if config.MaxBackups <= 0 {
+		config.MaxBackups = defaultMaxBackups
+	}
+
+	path, err := filepath.Abs(config.Output)
+	if err != nil {
+		return nil, err
+	}
+
+	logger := &Logger{
+		path:       path,
+		maxBytes:   int64(config.MaxSizeMB) * 1024 * 1024,
+		maxBackups: config.MaxBackups,
+	}
+
+	err = logger.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return logger, nil
+}
+
+//NewWithWriter creates a Logger writing to writer without rotation
+func NewWithWriter(writer io.Writer) *Logger {
+	return &Logger{writer: writer}
+}
+
+//Log writes event, errors are returned so that callers can report them in their own log
+func (logger *Logger) Log(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if logger.file != nil && logger.size+int64(len(line)) > logger.maxBytes && logger.size > 0 {
+		err = logger.rotate()
+		if err != nil {
+			return fmt.Errorf("Unable to rotate audit log: %s", err)
+		}
+	}
+
+	written, err := logger.writer.Write(line)
+	logger.size += int64(written)
+	return err
+}
+
+//Close closes the audit log file
+func (logger *Logger) Close() error {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+
+	if logger.file == nil {
+		return nil
+	}
+
+	err := logger.file.Close()
+	logger.file = nil
+	logger.writer = ioutil.Discard
+	return err
+}
+
+func (logger *Logger) open() error {
+	err := os.MkdirAll(filepath.Dir(logger.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(logger.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	logger.file = file
+	logger.writer = file
+	logger.size = info.Size()
+	return nil
+}
+
+//rotate must be called with the mutex held, it shifts path.1 to path.2 and so on, dropping the oldest backup
+func (logger *Logger) rotate() error {
+	logger.file.Close()
+
+	os.Remove(backupPath(logger.path, logger.maxBackups))
+	for i := logger.maxBackups - 1; i > 0; i-- {
+		os.Rename(backupPath(logger.path, i), backupPath(logger.path, i+1))
+	}
+
+	err := os.Rename(logger.path, backupPath(logger.path, 1))
+	if err != nil {
+		return err
+	}
+
+	return logger.open()
+}
+
+func backupPath(path string, index int) string {
+	return fmt.Sprintf("%s.%d", path, index)
+}
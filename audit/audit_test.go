@@ -0,0 +1,94 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+func TestLog(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewWithWriter(&buffer)
+
+	logger.Log(Event{Time: time.Now(), Type: EventRequest, ClientIP: "10.0.0.1", User: "user", Path: "/gorouters", Status: 200, Bytes: 42})
+	logger.Log(Event{Time: time.Now(), Type: EventDenied, ClientIP: "10.0.0.2", Path: "/kpis", Status: 401})
+
+	scanner := bufio.NewScanner(&buffer)
+	var events []map[string]interface{}
+	for scanner.Scan() {
+		event := make(map[string]interface{})
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("Expected JSON line, but received %s: %s", scanner.Text(), err.Error())
+		}
+		events = append(events, event)
+	}
+
+	t.Log("Check if every event is one JSON line... (expecting events: 2)")
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, but received %d", len(events))
+	}
+
+	t.Log("Check if event fields are written... (expecting user user and 42 bytes)")
+	if events[0]["event"] != EventRequest || events[0]["user"] != "user" || events[0]["bytes"] != float64(42) || events[0]["client_ip"] != "10.0.0.1" {
+		t.Errorf("Expected request event of user with 42 bytes, but received %v", events[0])
+	}
+
+	t.Log("Check if empty user is omitted... (expecting no user)")
+	if _, ok := events[1]["user"]; ok {
+		t.Errorf("Expected no user, but received %v", events[1]["user"])
+	}
+}
+
+func TestRotation(t *testing.T) {
+	directory, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %s", err.Error())
+	}
+	defer os.RemoveAll(directory)
+
+	path := filepath.Join(directory, "audit.log")
+	logger, err := New(&nozzleconfiguration.AuditConfiguration{Output: path, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Expected audit log, but received error %s", err.Error())
+	}
+	defer logger.Close()
+
+	logger.maxBytes = 200
+	for i := 0; i < 10; i++ {
+		if err := logger.Log(Event{Type: EventRequest, Path: "/gorouters", Status: 200}); err != nil {
+			t.Fatalf("Expected event to be written, but received error %s", err.Error())
+		}
+	}
+
+	t.Log("Check if audit log is rotated... (expecting 2 backups)")
+	for _, file := range []string{path, backupPath(path, 1), backupPath(path, 2)} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Errorf("Expected file %s, but received error %s", file, err.Error())
+			continue
+		}
+
+		if info.Size() > logger.maxBytes {
+			t.Errorf("Expected %s to be at most %d bytes, but received %d", file, logger.maxBytes, info.Size())
+		}
+
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected %s to have permissions 0600, but received %v", file, info.Mode().Perm())
+		}
+	}
+
+	t.Log("Check if oldest backups are dropped... (expecting no third backup)")
+	if _, err := os.Stat(backupPath(path, 3)); !os.IsNotExist(err) {
+		t.Errorf("Expected no file %s, but received %v", backupPath(path, 3), err)
+	}
+}
@@ -6,9 +6,15 @@ package bluemedorafirehosenozzle
 import (
     "crypto/tls"
     "fmt"
+    "os"
+    "os/signal"
+    "syscall"
     "time"
     
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/metronemitter"
     "github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/uaaauth"
     "github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
     "github.com/cloudfoundry/noaa/consumer"
     "github.com/cloudfoundry/sonde-go/events"
@@ -26,10 +32,23 @@ type BlueMedoraFirehoseNozzle struct {
     logger      *gosteno.Logger
     consumer    *consumer.Consumer
     server      *webserver.WebServer
+    sinks       []Sink
+    signals     chan os.Signal
+    envelopes   uint64
+    exportedStats stats.Snapshot
+    emitter     *metronemitter.MetronEmitter
 }
 
 //New BlueMedoraFirhoseNozzle
 func New(config *nozzleconfiguration.NozzleConfiguration, server *webserver.WebServer, logger *gosteno.Logger) *BlueMedoraFirehoseNozzle {
+    if config.Shutdown.TimeoutSeconds == 0 {
+        config.Shutdown.TimeoutSeconds = DefaultShutdownTimeoutSeconds
+    }
+    
+    if config.Metron.Origin == "" {
+        config.Metron.Origin = stats.DefaultOrigin
+    }
+    
     return &BlueMedoraFirehoseNozzle {
         config:     config,
         logger:     logger,
@@ -37,10 +56,15 @@ func New(config *nozzleconfiguration.NozzleConfiguration, server *webserver.WebS
     }
 }
 
-//Start starts consuming events from firehose
+//Start starts consuming events from firehose, it returns nil after a graceful shutdown on SIGTERM or SIGINT and
+//ErrShutdownTimeout when that shutdown did not finish in time
 func (nozzle *BlueMedoraFirehoseNozzle) Start() error {
     nozzle.logger.Info("Starting Blue Medora Firehose Nozzle")
     
+    nozzle.signals = make(chan os.Signal, 1)
+    signal.Notify(nozzle.signals, syscall.SIGTERM, syscall.SIGINT)
+    defer signal.Stop(nozzle.signals)
+    
     var authToken string
     if !nozzle.config.DisableAccessControl {
         authToken = nozzle.fetchUAAAuthToken()
@@ -50,6 +74,9 @@ func (nozzle *BlueMedoraFirehoseNozzle) Start() error {
     
     nozzle.serverErrs = nozzle.server.Start(webserver.DefaultKeyLocation, webserver.DefaultCertLocation)
     
+    nozzle.createSinks()
+    nozzle.createMetronEmitter()
+    
     nozzle.collectFromFirehose(authToken)
     err := nozzle.processMessages()
     
@@ -72,6 +99,14 @@ func (nozzle *BlueMedoraFirehoseNozzle) fetchUAAAuthToken() string {
     }
     
     nozzle.logger.Debug(fmt.Sprintf("Successfully fetched UAA authentication token <%s>", token))
+    
+    expiry, err := uaaauth.TokenExpiry(token)
+    if err != nil {
+        nozzle.logger.Warnf("Unable to read expiry of UAA authentication token: %s", err.Error())
+    } else {
+        nozzle.server.SetUAATokenExpiry(expiry)
+    }
+    
     return token
 }
 
@@ -81,6 +116,12 @@ func (nozzle *BlueMedoraFirehoseNozzle) collectFromFirehose(authToken string) {
     debugPrinter := &BMDebugPrinter{nozzle.logger}
     nozzle.consumer.SetDebugPrinter(debugPrinter)
     nozzle.consumer.SetIdleTimeout(time.Duration(nozzle.config.IdleTimeoutSeconds) * time.Second)
+    nozzle.consumer.SetOnConnectCallback(func() {
+        nozzle.logger.Info("Connected to firehose")
+        nozzle.server.SetFirehoseState(webserver.FirehoseConnected)
+    })
+    
+    nozzle.server.SetFirehoseState(webserver.FirehoseConnecting)
     nozzle.messages, nozzle.errs = nozzle.consumer.Firehose(nozzle.config.SubscriptionID, authToken)
 }
 
@@ -93,26 +134,55 @@ func (nozzle *BlueMedoraFirehoseNozzle) processMessages() error {
                 nozzle.flushMetricCaches()
             case envelope := <-nozzle.messages:
                 nozzle.cacheEnvelope(envelope)
-            case err := <-nozzle.serverErrs:
-                if err != nil {
+            case err, ok := <-nozzle.serverErrs:
+                if !ok {
+                    //Every listener was shut down, stop selecting on the closed channel
+                    nozzle.serverErrs = nil
+                } else if err != nil {
                     nozzle.logger.Errorf("Error while running webserver: %s", err)
+                    nozzle.closeSinks()
                     return err
                 }
             case err := <-nozzle.errs:
                 if err != nil {
                     nozzle.handleError(err)
+                    nozzle.closeSinks()
                     return err
                 }
+            case sig := <-nozzle.signals:
+                return nozzle.shutdown(sig)
         }
     }
 }
 
 func (nozzle *BlueMedoraFirehoseNozzle) cacheEnvelope(envelope *events.Envelope) {
+    nozzle.envelopes++
+    
+    //The nozzle's own metrics emitted to Metron come back through the firehose, they already reached the sinks
+    if nozzle.config.Metron.Enabled && envelope.GetOrigin() == nozzle.selfOrigin() {
+        nozzle.server.RecordOwnEnvelope()
+        return
+    }
+    
     nozzle.server.CacheEnvelope(envelope)
+    
+    for _, sink := range nozzle.sinks {
+        sink.SendEnvelope(envelope)
+    }
 }
 
 func (nozzle *BlueMedoraFirehoseNozzle) flushMetricCaches() {
+    start := time.Now()
+    if len(nozzle.sinks) > 0 {
+        snapshot := nozzle.server.Snapshot()
+        nozzle.exportSelfStats(snapshot)
+        for _, sink := range nozzle.sinks {
+            sink.SendResources(snapshot)
+        }
+    }
+    
     nozzle.server.ClearCache()
+    nozzle.server.RecordFlush(time.Since(start))
 }
 
 func (nozzle *BlueMedoraFirehoseNozzle) handleError(err error) {
@@ -131,6 +201,7 @@ func (nozzle *BlueMedoraFirehoseNozzle) handleError(err error) {
             nozzle.logger.Errorf("Error while reading from firehose: %s", err.Error())
     }
 
+    nozzle.server.SetFirehoseState(webserver.FirehoseDisconnected)
     nozzle.consumer.Close()
     nozzle.flushMetricCaches()
 }
@@ -0,0 +1,86 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package bluemedorafirehosenozzle
+
+import (
+    "os"
+    "time"
+
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/metronemitter"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+)
+
+const (
+    instanceIndexEnv = "CF_INSTANCE_INDEX"
+    instanceIPEnv    = "CF_INSTANCE_IP"
+)
+
+//selfOrigin is the origin of the nozzle's own metrics, also when the config has no Metron section
+func (nozzle *BlueMedoraFirehoseNozzle) selfOrigin() string {
+    if nozzle.config.Metron.Origin == "" {
+        return stats.DefaultOrigin
+    }
+    return nozzle.config.Metron.Origin
+}
+
+//selfSource identifies this nozzle instance, Cloud Foundry sets the index and IP when it runs as an app
+func (nozzle *BlueMedoraFirehoseNozzle) selfSource() stats.Source {
+    origin := nozzle.selfOrigin()
+    source := stats.Source{
+        Origin: origin,
+        Job:    origin,
+        Index:  os.Getenv(instanceIndexEnv),
+        IP:     os.Getenv(instanceIPEnv),
+    }
+
+    if source.Index == "" {
+        source.Index = "0"
+    }
+
+    return source
+}
+
+//exportSelfStats sends the nozzle's own metrics to the sinks as envelopes and adds them to snapshot as a resource
+func (nozzle *BlueMedoraFirehoseNozzle) exportSelfStats(snapshot map[string][]webserver.Resource) {
+    selfStats := nozzle.server.SelfStats()
+    source := nozzle.selfSource()
+
+    for _, envelope := range selfStats.Envelopes(source, nozzle.exportedStats, time.Now()) {
+        for _, sink := range nozzle.sinks {
+            sink.SendEnvelope(envelope)
+        }
+    }
+    nozzle.exportedStats = selfStats
+
+    resource := webserver.Resource{
+        Job:            source.Job,
+        Index:          source.Index,
+        IP:             source.IP,
+        ValueMetrics:   make(map[string]float64, len(selfStats.Gauges)),
+        CounterMetrics: make(map[string]float64, len(selfStats.Counters)),
+    }
+
+    for name, value := range selfStats.Gauges {
+        resource.ValueMetrics[name] = value
+    }
+
+    for name, value := range selfStats.Counters {
+        resource.CounterMetrics[name] = float64(value)
+    }
+
+    snapshot[source.Origin] = append(snapshot[source.Origin], resource)
+}
+
+func (nozzle *BlueMedoraFirehoseNozzle) createMetronEmitter() {
+    if !nozzle.config.Metron.Enabled {
+        return
+    }
+
+    emitter, err := metronemitter.New(&nozzle.config.Metron, nozzle.selfSource(), nozzle.server.SelfStats, nozzle.logger)
+    if err != nil {
+        nozzle.logger.Fatalf("Error creating Metron emitter: %s", err.Error())
+    }
+    nozzle.emitter = emitter
+}
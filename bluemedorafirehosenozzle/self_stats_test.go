@@ -0,0 +1,102 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package bluemedorafirehosenozzle
+
+import (
+    "testing"
+
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+    "github.com/cloudfoundry/sonde-go/events"
+)
+
+func TestExportSelfStats(t *testing.T) {
+    nozzle, sink := createShutdownNozzle("", 5)
+
+    for _, index := range []string{"0", "1", "1"} {
+        nozzle.cacheEnvelope(createValueMetric(index))
+    }
+    nozzle.flushMetricCaches()
+
+    t.Log("Check if the nozzle's own metrics are flushed as resource... (expecting 1 resource with 3 envelopes received)")
+    if len(sink.selfResources) != 1 || sink.selfResources[0].CounterMetrics[stats.EnvelopesReceived] != 3 {
+        t.Fatalf("Expected 1 resource with 3 envelopes received, but received %+v", sink.selfResources)
+    }
+
+    t.Log("Check if cache size is exported... (expecting 2 cached resources)")
+    if sink.selfResources[0].ValueMetrics[stats.CacheResources] != 2 {
+        t.Errorf("Expected 2 cached resources, but received %v", sink.selfResources[0].ValueMetrics[stats.CacheResources])
+    }
+
+    received := findCounterEvent(sink.envelopes, stats.EnvelopesReceived)
+    t.Log("Check if the nozzle's own metrics are sent as envelopes... (expecting counter event with delta 3)")
+    if received == nil || received.GetOrigin() != stats.DefaultOrigin || received.GetCounterEvent().GetDelta() != 3 {
+        t.Fatalf("Expected counter event with delta 3, but received %v", received)
+    }
+
+    nozzle.cacheEnvelope(createValueMetric("2"))
+    sink.envelopes = nil
+    nozzle.flushMetricCaches()
+
+    received = findCounterEvent(sink.envelopes, stats.EnvelopesReceived)
+    t.Log("Check if the next flush sends the delta since the last one... (expecting delta 1 and total 4)")
+    if received == nil || received.GetCounterEvent().GetDelta() != 1 || received.GetCounterEvent().GetTotal() != 4 {
+        t.Errorf("Expected counter event with delta 1 and total 4, but received %v", received)
+    }
+}
+
+func TestOwnEnvelopesAreNotCached(t *testing.T) {
+    nozzle, sink := createShutdownNozzle("", 5)
+    nozzle.config.Metron.Enabled = true
+
+    envelope := createValueMetric("0")
+    envelope.Origin = &nozzle.config.Metron.Origin
+    nozzle.cacheEnvelope(envelope)
+
+    t.Logf("Check if envelope with the nozzle's own origin %s is dropped... (expecting empty cache)", stats.DefaultOrigin)
+    if resources := nozzle.server.Snapshot(); len(resources) != 0 || len(sink.envelopes) != 0 {
+        t.Errorf("Expected empty cache and no forwarded envelopes, but received %v and %d envelopes", resources, len(sink.envelopes))
+    }
+
+    t.Log("Check if dropped envelope is counted... (expecting 1 own envelope)")
+    if own := nozzle.server.SelfStats().Counters[stats.EnvelopesOwn]; own != 1 {
+        t.Errorf("Expected 1 own envelope, but received %d", own)
+    }
+
+    nozzle.config.Metron.Enabled = false
+    nozzle.cacheEnvelope(envelope)
+
+    t.Log("Check if envelope with the same origin is cached when Metron is disabled... (expecting 1 cached resource)")
+    if resources := nozzle.server.Snapshot(); len(resources[stats.DefaultOrigin]) != 1 || len(sink.envelopes) != 1 {
+        t.Errorf("Expected 1 cached resource and 1 forwarded envelope, but received %v and %d envelopes", resources, len(sink.envelopes))
+    }
+}
+
+func TestSelfSourceWithoutMetronConfig(t *testing.T) {
+    nozzle, sink := createShutdownNozzle("", 5)
+    nozzle.config.Metron = nozzleconfiguration.MetronConfiguration{}
+
+    t.Logf("Check if the nozzle's own origin defaults without Metron config... (expecting origin: %s)", stats.DefaultOrigin)
+    if source := nozzle.selfSource(); source.Origin != stats.DefaultOrigin || source.Job != stats.DefaultOrigin {
+        t.Errorf("Expected origin and job %s, but received %+v", stats.DefaultOrigin, source)
+    }
+
+    nozzle.cacheEnvelope(createValueMetric("0"))
+    nozzle.flushMetricCaches()
+
+    received := findCounterEvent(sink.envelopes, stats.EnvelopesReceived)
+    t.Logf("Check if the nozzle's own envelopes carry the default origin... (expecting origin: %s)", stats.DefaultOrigin)
+    if received == nil || received.GetOrigin() != stats.DefaultOrigin {
+        t.Errorf("Expected counter event with origin %s, but received %v", stats.DefaultOrigin, received)
+    }
+}
+
+func findCounterEvent(envelopes []*events.Envelope, name string) *events.Envelope {
+    for _, envelope := range envelopes {
+        if envelope.GetCounterEvent().GetName() == name {
+            return envelope
+        }
+    }
+    return nil
+}
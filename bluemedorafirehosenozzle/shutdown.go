@@ -0,0 +1,114 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package bluemedorafirehosenozzle
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "time"
+    
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+)
+
+const (
+    //DefaultShutdownTimeoutSeconds is the graceful shutdown deadline when Shutdown.TimeoutSeconds is not set
+    DefaultShutdownTimeoutSeconds = 30
+)
+
+//ErrShutdownTimeout is returned by Start when the graceful shutdown did not finish within Shutdown.TimeoutSeconds
+var ErrShutdownTimeout = errors.New("graceful shutdown timed out")
+
+//FinalState is logged and written to Shutdown.StateFile once the nozzle has shut down
+type FinalState struct {
+    StoppedAt         time.Time
+    Signal            string
+    EnvelopesReceived uint64
+    FlushedResources  int
+    Sinks             int
+}
+
+//shutdown stops the API, closes the firehose subscription, flushes the sinks and records the final state, all
+//within the configured deadline
+func (nozzle *BlueMedoraFirehoseNozzle) shutdown(sig os.Signal) error {
+    timeout := time.Duration(nozzle.config.Shutdown.TimeoutSeconds) * time.Second
+    nozzle.logger.Infof("Received %s, shutting down within %s", sig, timeout)
+
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        nozzle.stop(ctx, sig)
+    }()
+
+    select {
+        case <-done:
+            nozzle.logger.Info("Shutdown complete")
+            return nil
+        case <-ctx.Done():
+            nozzle.logger.Errorf("Shutdown did not finish within %s", timeout)
+            return ErrShutdownTimeout
+    }
+}
+
+func (nozzle *BlueMedoraFirehoseNozzle) stop(ctx context.Context, sig os.Signal) {
+    nozzle.logger.Info("Stopping API and draining active requests")
+    err := nozzle.server.Shutdown(ctx)
+    if err != nil {
+        nozzle.logger.Errorf("Error stopping API: %s", err.Error())
+    }
+
+    if nozzle.consumer != nil {
+        nozzle.logger.Info("Closing firehose subscription")
+        nozzle.server.SetFirehoseState(webserver.FirehoseDisconnected)
+        err = nozzle.consumer.Close()
+        if err != nil {
+            nozzle.logger.Errorf("Error closing firehose subscription: %s", err.Error())
+        }
+    }
+
+    state := FinalState{
+        Signal:            sig.String(),
+        EnvelopesReceived: nozzle.envelopes,
+        Sinks:             len(nozzle.sinks),
+    }
+
+    for _, resources := range nozzle.server.Snapshot() {
+        state.FlushedResources += len(resources)
+    }
+
+    nozzle.logger.Info("Flushing sinks")
+    nozzle.flushMetricCaches()
+    nozzle.closeSinks()
+
+    state.StoppedAt = time.Now().UTC()
+    nozzle.writeFinalState(state)
+}
+
+func (nozzle *BlueMedoraFirehoseNozzle) writeFinalState(state FinalState) {
+    nozzle.logger.Infof("Final state: stopped by %s after %d envelopes, flushed %d resources to %d sinks",
+        state.Signal, state.EnvelopesReceived, state.FlushedResources, state.Sinks)
+
+    stateFile := nozzle.config.Shutdown.StateFile
+    if stateFile == "" {
+        return
+    }
+
+    stateBytes, err := json.MarshalIndent(state, "", "    ")
+    if err == nil {
+        err = os.MkdirAll(filepath.Dir(stateFile), 0755)
+    }
+    if err == nil {
+        err = ioutil.WriteFile(stateFile, stateBytes, 0644)
+    }
+
+    if err != nil {
+        nozzle.logger.Errorf("Error writing final state to %s: %s", stateFile, err.Error())
+    }
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package bluemedorafirehosenozzle
+
+import (
+    "encoding/json"
+    "io/ioutil"
+    "os"
+    "path/filepath"
+    "syscall"
+    "testing"
+    "time"
+
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+    "github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+    defaultLogDirectory = "../logs"
+    nozzleLogFile       = "bm_nozzle.log"
+    nozzleLogName       = "bm_firehose_nozzle"
+    nozzleLogLevel      = "debug"
+)
+
+type fakeSink struct {
+    resources     int
+    selfResources []webserver.Resource
+    envelopes     []*events.Envelope
+    closed        bool
+    closeDelay    time.Duration
+}
+
+func (sink *fakeSink) SendEnvelope(envelope *events.Envelope) {
+    sink.envelopes = append(sink.envelopes, envelope)
+}
+
+//SendResources counts the cached resources apart from the resource of the nozzle's own metrics
+func (sink *fakeSink) SendResources(snapshot map[string][]webserver.Resource) {
+    for origin, resources := range snapshot {
+        if origin == stats.DefaultOrigin {
+            sink.selfResources = append(sink.selfResources, resources...)
+            continue
+        }
+        sink.resources += len(resources)
+    }
+}
+
+func (sink *fakeSink) Close() error {
+    time.Sleep(sink.closeDelay)
+    sink.closed = true
+    return nil
+}
+
+func TestShutdown(t *testing.T) {
+    directory, err := ioutil.TempDir("", "shutdown")
+    if err != nil {
+        t.Fatalf("Error creating temp directory: %s", err.Error())
+    }
+    defer os.RemoveAll(directory)
+
+    stateFile := filepath.Join(directory, "state.json")
+    nozzle, sink := createShutdownNozzle(stateFile, 5)
+
+    for _, index := range []string{"0", "1"} {
+        nozzle.cacheEnvelope(createValueMetric(index))
+    }
+
+    t.Log("Check if shutdown completes... (expecting no error)")
+    if err := nozzle.shutdown(syscall.SIGTERM); err != nil {
+        t.Errorf("Expected no error, but received %s", err.Error())
+    }
+
+    t.Log("Check if cached resources are flushed and sinks closed... (expecting 2 resources)")
+    if sink.resources != 2 || !sink.closed {
+        t.Errorf("Expected 2 flushed resources and closed sink, but received %d resources and closed %v", sink.resources, sink.closed)
+    }
+
+    var state FinalState
+    stateBytes, err := ioutil.ReadFile(stateFile)
+    if err == nil {
+        err = json.Unmarshal(stateBytes, &state)
+    }
+
+    t.Log("Check if final state is written... (expecting 2 envelopes stopped by terminated)")
+    if err != nil || state.EnvelopesReceived != 2 || state.FlushedResources != 2 || state.Sinks != 1 || state.Signal != syscall.SIGTERM.String() {
+        t.Errorf("Expected final state with 2 envelopes, but received %+v and error %v", state, err)
+    }
+}
+
+func TestShutdownTimeout(t *testing.T) {
+    nozzle, sink := createShutdownNozzle("", 1)
+    sink.closeDelay = 3 * time.Second
+
+    t.Log("Check if a shutdown exceeding its deadline is reported... (expecting ErrShutdownTimeout)")
+    if err := nozzle.shutdown(syscall.SIGINT); err != ErrShutdownTimeout {
+        t.Errorf("Expected error %v, but received %v", ErrShutdownTimeout, err)
+    }
+}
+
+func createShutdownNozzle(stateFile string, timeoutSeconds uint32) (*BlueMedoraFirehoseNozzle, *fakeSink) {
+    logger.CreateLogDirectory(defaultLogDirectory)
+    testLogger := logger.New(defaultLogDirectory, nozzleLogFile, nozzleLogName, nozzleLogLevel)
+
+    config := &nozzleconfiguration.NozzleConfiguration{
+        UAAUsername:          "user",
+        UAAPassword:          "password",
+        DisableAccessControl: true,
+        Shutdown: nozzleconfiguration.ShutdownConfiguration{
+            TimeoutSeconds: timeoutSeconds,
+            StateFile:      stateFile,
+        },
+    }
+
+    sink := &fakeSink{}
+    nozzle := New(config, webserver.New(config, testLogger), testLogger)
+    nozzle.sinks = []Sink{sink}
+    return nozzle, sink
+}
+
+func createValueMetric(index string) *events.Envelope {
+    origin := "gorouter"
+    deployment := "cf"
+    job := "router"
+    ip := "10.0.0.1"
+    name := "latency"
+    value := 1.5
+    eventType := events.Envelope_ValueMetric
+    timestamp := time.Now().UnixNano()
+
+    return &events.Envelope{
+        Origin:      &origin,
+        EventType:   &eventType,
+        Timestamp:   &timestamp,
+        Deployment:  &deployment,
+        Job:         &job,
+        Index:       &index,
+        Ip:          &ip,
+        ValueMetric: &events.ValueMetric{Name: &name, Value: &value},
+    }
+}
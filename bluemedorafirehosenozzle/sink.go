@@ -0,0 +1,67 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package bluemedorafirehosenozzle
+
+import (
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/kafkasink"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/syslogsink"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/webhooksink"
+    "github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+    "github.com/cloudfoundry/sonde-go/events"
+)
+
+//Sink receives firehose data alongside the webserver cache
+type Sink interface {
+    //SendEnvelope is called for every envelope read from the firehose and must not block
+    SendEnvelope(envelope *events.Envelope)
+    //SendResources is called with the cached resources right before the cache is flushed
+    SendResources(snapshot map[string][]webserver.Resource)
+    //Close flushes any buffered data
+    Close() error
+}
+
+func (nozzle *BlueMedoraFirehoseNozzle) createSinks() {
+    if nozzle.config.Kafka.Enabled {
+        sink, err := kafkasink.New(&nozzle.config.Kafka, nozzle.logger)
+        if err != nil {
+            nozzle.logger.Fatalf("Error creating Kafka sink: %s", err.Error())
+        }
+        nozzle.sinks = append(nozzle.sinks, sink)
+    }
+    
+    if nozzle.config.Syslog.Enabled {
+        sink, err := syslogsink.New(&nozzle.config.Syslog, nozzle.logger)
+        if err != nil {
+            nozzle.logger.Fatalf("Error creating syslog sink: %s", err.Error())
+        }
+        nozzle.sinks = append(nozzle.sinks, sink)
+    }
+    
+    if nozzle.config.Webhook.Enabled {
+        sink, err := webhooksink.New(&nozzle.config.Webhook, nozzle.logger)
+        if err != nil {
+            nozzle.logger.Fatalf("Error creating webhook sink: %s", err.Error())
+        }
+        nozzle.sinks = append(nozzle.sinks, sink)
+    }
+}
+
+//closeSinks also closes the Metron emitter, which sends the final self metrics
+func (nozzle *BlueMedoraFirehoseNozzle) closeSinks() {
+    for _, sink := range nozzle.sinks {
+        err := sink.Close()
+        if err != nil {
+            nozzle.logger.Errorf("Error closing sink: %s", err.Error())
+        }
+    }
+    nozzle.sinks = nil
+    
+    if nozzle.emitter != nil {
+        err := nozzle.emitter.Close()
+        if err != nil {
+            nozzle.logger.Errorf("Error closing Metron emitter: %s", err.Error())
+        }
+        nozzle.emitter = nil
+    }
+}
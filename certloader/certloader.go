@@ -0,0 +1,179 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package certloader
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cloudfoundry/gosteno"
+)
+
+//Loader serves a TLS certificate and key pair and reloads it when the files change or on SIGHUP
+type Loader struct {
+	certFile string
+	keyFile  string
+	logger   *gosteno.Logger
+
+	mutex       sync.RWMutex
+	certificate *tls.Certificate
+
+	fileMutex sync.Mutex
+	certStamp fileStamp
+	keyStamp  fileStamp
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+//fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+//New loads the key pair, unlike reloads a failure here is returned
+func New(certFile string, keyFile string, logger *gosteno.Logger) (*Loader, error) {
+	loader := &Loader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+
+	loader.certStamp, loader.keyStamp = loader.stamps()
+	certificate, err := loadCertificate(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	loader.certificate = certificate
+	logger.Infof("Loaded TLS certificate %s expiring %s", describe(certificate), certificate.Leaf.NotAfter.Format(time.RFC3339))
+	return loader, nil
+}
+
+//GetCertificate is used as tls.Config.GetCertificate
+func (loader *Loader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	loader.mutex.RLock()
+	defer loader.mutex.RUnlock()
+	return loader.certificate, nil
+}
+
+//Reload replaces the key pair with the current files, the old pair is kept when they are invalid
+func (loader *Loader) Reload() error {
+	loader.fileMutex.Lock()
+	loader.certStamp, loader.keyStamp = loader.stamps()
+	loader.fileMutex.Unlock()
+
+	certificate, err := loadCertificate(loader.certFile, loader.keyFile)
+	if err != nil {
+		loader.logger.Errorf("Keeping current TLS certificate, unable to reload %s and %s: %s", loader.certFile, loader.keyFile, err.Error())
+		return err
+	}
+
+	loader.mutex.Lock()
+	loader.certificate = certificate
+	loader.mutex.Unlock()
+
+	loader.logger.Infof("Reloaded TLS certificate %s expiring %s", describe(certificate), certificate.Leaf.NotAfter.Format(time.RFC3339))
+	return nil
+}
+
+//Watch reloads the key pair on SIGHUP and when the files change, they are checked every interval
+func (loader *Loader) Watch(interval time.Duration) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(hangups)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-hangups:
+				loader.logger.Info("Received SIGHUP, reloading TLS certificate")
+				loader.Reload()
+			case <-ticker.C:
+				if loader.changed() {
+					loader.logger.Info("TLS certificate files changed, reloading TLS certificate")
+					loader.Reload()
+				}
+			case <-loader.stop:
+				return
+			}
+		}
+	}()
+}
+
+//Stop stops watching for changes
+func (loader *Loader) Stop() {
+	loader.stopOnce.Do(func() {
+		close(loader.stop)
+	})
+}
+
+func (loader *Loader) changed() bool {
+	certStamp, keyStamp := loader.stamps()
+
+	loader.fileMutex.Lock()
+	defer loader.fileMutex.Unlock()
+	return certStamp != loader.certStamp || keyStamp != loader.keyStamp
+}
+
+func (loader *Loader) stamps() (fileStamp, fileStamp) {
+	return stamp(loader.certFile), stamp(loader.keyFile)
+}
+
+func stamp(path string) fileStamp {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}
+}
+
+//loadCertificate also rejects certificates that are expired or not valid yet
+func loadCertificate(certFile string, keyFile string) (*tls.Certificate, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired at %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if now.Before(leaf.NotBefore) {
+		return nil, fmt.Errorf("certificate is not valid before %s", leaf.NotBefore.Format(time.RFC3339))
+	}
+
+	certificate.Leaf = leaf
+	return &certificate, nil
+}
+
+//Fingerprint is the SHA-256 fingerprint of the DER encoded certificate
+func Fingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func describe(certificate *tls.Certificate) string {
+	return fmt.Sprintf("%q (SHA-256 %s)", certificate.Leaf.Subject.String(), Fingerprint(certificate.Leaf))
+}
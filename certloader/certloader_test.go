@@ -0,0 +1,166 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package certloader
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	loaderLogFile       = "bm_certloader.log"
+	loaderLogName       = "bm_certloader"
+	loaderLogLevel      = "debug"
+)
+
+func TestReload(t *testing.T) {
+	directory, certFile, keyFile := createTestDirectory(t)
+	defer os.RemoveAll(directory)
+
+	writeTestCertificate(t, certFile, keyFile, "first", time.Hour)
+	loader, err := New(certFile, keyFile, createTestLogger())
+	if err != nil {
+		t.Fatalf("Expected certificate to load, but received error %s", err.Error())
+	}
+
+	t.Log("Check if certificate is served... (expecting common name: first)")
+	checkCommonName(t, loader, "first")
+
+	ioutil.WriteFile(certFile, []byte("not a certificate"), 0644)
+	t.Log("Check if invalid certificate is rejected... (expected error)")
+	if err := loader.Reload(); err == nil {
+		t.Error("Expected error reloading invalid certificate, but received none")
+	}
+	checkCommonName(t, loader, "first")
+
+	writeTestCertificate(t, certFile, keyFile, "expired", -time.Minute)
+	t.Log("Check if expired certificate is rejected... (expected error)")
+	if err := loader.Reload(); err == nil {
+		t.Error("Expected error reloading expired certificate, but received none")
+	}
+	checkCommonName(t, loader, "first")
+
+	writeTestCertificate(t, certFile, keyFile, "second", time.Hour)
+	t.Log("Check if valid certificate is reloaded... (expecting common name: second)")
+	if err := loader.Reload(); err != nil {
+		t.Errorf("Expected certificate to reload, but received error %s", err.Error())
+	}
+	checkCommonName(t, loader, "second")
+}
+
+func TestWatch(t *testing.T) {
+	directory, certFile, keyFile := createTestDirectory(t)
+	defer os.RemoveAll(directory)
+
+	writeTestCertificate(t, certFile, keyFile, "first", time.Hour)
+	loader, err := New(certFile, keyFile, createTestLogger())
+	if err != nil {
+		t.Fatalf("Expected certificate to load, but received error %s", err.Error())
+	}
+
+	loader.Watch(10 * time.Millisecond)
+	defer loader.Stop()
+
+	writeTestCertificate(t, certFile, keyFile, "changed", time.Hour)
+	t.Log("Check if changed files are reloaded... (expecting common name: changed)")
+	waitForCommonName(t, loader, "changed")
+}
+
+func TestHangup(t *testing.T) {
+	directory, certFile, keyFile := createTestDirectory(t)
+	defer os.RemoveAll(directory)
+
+	writeTestCertificate(t, certFile, keyFile, "first", time.Hour)
+	loader, err := New(certFile, keyFile, createTestLogger())
+	if err != nil {
+		t.Fatalf("Expected certificate to load, but received error %s", err.Error())
+	}
+
+	//The files are not checked during the test, only the signal can trigger the reload
+	loader.Watch(time.Hour)
+	defer loader.Stop()
+
+	writeTestCertificate(t, certFile, keyFile, "signaled", time.Hour)
+	t.Log("Check if SIGHUP reloads the certificate... (expecting common name: signaled)")
+	syscall.Kill(os.Getpid(), syscall.SIGHUP)
+	waitForCommonName(t, loader, "signaled")
+}
+
+func createTestLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, loaderLogFile, loaderLogName, loaderLogLevel)
+}
+
+func checkCommonName(t *testing.T, loader *Loader, commonName string) {
+	certificate, _ := loader.GetCertificate(nil)
+	if certificate.Leaf.Subject.CommonName != commonName {
+		t.Errorf("Expected certificate of %s, but received %s", commonName, certificate.Leaf.Subject.CommonName)
+	}
+}
+
+func waitForCommonName(t *testing.T, loader *Loader, commonName string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if certificate, _ := loader.GetCertificate(nil); certificate.Leaf.Subject.CommonName == commonName {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkCommonName(t, loader, commonName)
+}
+
+func createTestDirectory(t *testing.T) (string, string, string) {
+	directory, err := ioutil.TempDir("", "certloader")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %s", err.Error())
+	}
+
+	return directory, filepath.Join(directory, "cert.pem"), filepath.Join(directory, "key.pem")
+}
+
+func writeTestCertificate(t *testing.T, certFile string, keyFile string, commonName string, validFor time.Duration) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+
+	notAfter := time.Now().Add(validFor)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    notAfter.Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Error marshaling key: %s", err.Error())
+	}
+
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes}), 0600)
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package certloader
+
+import (
+	"crypto/ecdsa"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGenerateSelfSigned(t *testing.T) {
+	directory, _, _ := createTestDirectory(t)
+	defer os.RemoveAll(directory)
+
+	certFile := filepath.Join(directory, "certs", "cert.pem")
+	keyFile := filepath.Join(directory, "certs", "key.pem")
+
+	certificate, err := GenerateSelfSigned(certFile, keyFile, []string{"nozzle.example.com", "10.0.0.1"}, 30*24*time.Hour)
+	if err != nil {
+		t.Fatalf("Expected self-signed certificate, but received error %s", err.Error())
+	}
+
+	t.Log("Check if certificate uses an ECDSA key... (expecting ECDSA public key)")
+	if _, ok := certificate.PublicKey.(*ecdsa.PublicKey); !ok {
+		t.Errorf("Expected ECDSA public key, but received %T", certificate.PublicKey)
+	}
+
+	t.Log("Check if hosts are subject alternative names... (expecting nozzle.example.com and 10.0.0.1)")
+	if err := certificate.VerifyHostname("nozzle.example.com"); err != nil {
+		t.Errorf("Expected certificate for nozzle.example.com, but received error %s", err.Error())
+	}
+	if err := certificate.VerifyHostname("10.0.0.1"); err != nil {
+		t.Errorf("Expected certificate for 10.0.0.1, but received error %s", err.Error())
+	}
+
+	t.Log("Check if certificate is valid for 30 days... (expecting 30 days)")
+	if validFor := certificate.NotAfter.Sub(certificate.NotBefore); validFor != 30*24*time.Hour {
+		t.Errorf("Expected validity of 30 days, but received %s", validFor)
+	}
+
+	t.Log("Check if files have safe permissions... (expecting key 0600, cert 0644)")
+	for file, perm := range map[string]os.FileMode{keyFile: 0600, certFile: 0644, filepath.Dir(certFile): 0700} {
+		info, err := os.Stat(file)
+		if err != nil {
+			t.Errorf("Expected file %s, but received error %s", file, err.Error())
+		} else if info.Mode().Perm() != perm {
+			t.Errorf("Expected %s to have permissions %v, but received %v", file, perm, info.Mode().Perm())
+		}
+	}
+
+	t.Log("Check if the generated pair can be loaded... (expecting no error)")
+	loader, err := New(certFile, keyFile, createTestLogger())
+	if err != nil {
+		t.Fatalf("Expected generated certificate to load, but received error %s", err.Error())
+	}
+	checkCommonName(t, loader, "nozzle.example.com")
+}
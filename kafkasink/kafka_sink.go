@@ -0,0 +1,277 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package kafkasink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+	"github.com/Shopify/sarama"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+//Kafka sink constants
+const (
+	ModeEnvelopes = "envelopes"
+	ModeResources = "resources"
+
+	EncodingProtobuf = "protobuf"
+	EncodingJSON     = "json"
+
+	defaultBufferSize = 10000
+)
+
+//DeliveryMetrics counts messages handled by the sink since it was created
+type DeliveryMetrics struct {
+	Sent      uint64
+	Delivered uint64
+	Failed    uint64
+	Dropped   uint64
+}
+
+//KafkaSink publishes firehose envelopes or cached resources to a Kafka topic
+type KafkaSink struct {
+	//Counters are kept first to stay 64-bit aligned for atomic access
+	sent      uint64
+	delivered uint64
+	failed    uint64
+	dropped   uint64
+
+	config   *nozzleconfiguration.KafkaConfiguration
+	logger   *gosteno.Logger
+	producer sarama.AsyncProducer
+	messages chan *sarama.ProducerMessage
+	wait     sync.WaitGroup
+}
+
+type resourceMessage struct {
+	Origin string
+	webserver.Resource
+}
+
+//New creates a KafkaSink connected to the configured brokers
+func New(config *nozzleconfiguration.KafkaConfiguration, logger *gosteno.Logger) (*KafkaSink, error) {
+	if len(config.Brokers) == 0 {
+		return nil, fmt.Errorf("No Kafka brokers configured")
+	}
+
+	if config.Topic == "" {
+		return nil, fmt.Errorf("No Kafka topic configured")
+	}
+
+	if config.Mode == "" {
+		config.Mode = ModeEnvelopes
+	}
+
+	//Resources can only be encoded as JSON, envelopes default to protobuf
+	if config.Encoding == "" && config.Mode == ModeResources {
+		config.Encoding = EncodingJSON
+	} else if config.Encoding == "" {
+		config.Encoding = EncodingProtobuf
+	}
+
+	if config.Mode != ModeEnvelopes && config.Mode != ModeResources {
+		return nil, fmt.Errorf("Unknown Kafka mode %s", config.Mode)
+	}
+
+	if config.Encoding != EncodingProtobuf && config.Encoding != EncodingJSON {
+		return nil, fmt.Errorf("Unknown Kafka encoding %s", config.Encoding)
+	}
+
+	if config.Mode == ModeResources && config.Encoding != EncodingJSON {
+		return nil, fmt.Errorf("Kafka mode %s only supports %s encoding", ModeResources, EncodingJSON)
+	}
+
+	producerConfig, err := createProducerConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	producer, err := sarama.NewAsyncProducer(config.Brokers, producerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Kafka producer: %s", err)
+	}
+
+	sink := &KafkaSink{
+		config:   config,
+		logger:   logger,
+		producer: producer,
+		messages: make(chan *sarama.ProducerMessage, defaultBufferSize),
+	}
+
+	sink.wait.Add(3)
+	go sink.forwardMessages()
+	go sink.readSuccesses()
+	go sink.readErrors()
+
+	logger.Infof("Publishing %s to Kafka topic %s", config.Mode, config.Topic)
+	return sink, nil
+}
+
+func createProducerConfig(config *nozzleconfiguration.KafkaConfiguration) (*sarama.Config, error) {
+	producerConfig := sarama.NewConfig()
+	producerConfig.ClientID = "bluemedora-firehose-nozzle"
+	producerConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	producerConfig.Producer.Return.Successes = true
+	producerConfig.Producer.Return.Errors = true
+
+	if config.TLSEnabled {
+		tlsConfig, err := createTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		producerConfig.Net.TLS.Enable = true
+		producerConfig.Net.TLS.Config = tlsConfig
+	}
+
+	if config.SASLUsername != "" {
+		producerConfig.Net.SASL.Enable = true
+		producerConfig.Net.SASL.User = config.SASLUsername
+		producerConfig.Net.SASL.Password = config.SASLPassword
+	}
+
+	return producerConfig, nil
+}
+
+func createTLSConfig(config *nozzleconfiguration.KafkaConfiguration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.TLSInsecureSkipVerify}
+
+	if config.TLSCAFile != "" {
+		caBytes, err := ioutil.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading Kafka CA file: %s", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("No certificates found in Kafka CA file %s", config.TLSCAFile)
+		}
+	}
+
+	if config.TLSCertFile != "" || config.TLSKeyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error loading Kafka client certificate: %s", err)
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
+
+//SendEnvelope publishes a raw envelope when the sink runs in envelopes mode
+func (sink *KafkaSink) SendEnvelope(envelope *events.Envelope) {
+	if sink.config.Mode != ModeEnvelopes {
+		return
+	}
+
+	var value []byte
+	var err error
+	if sink.config.Encoding == EncodingJSON {
+		value, err = json.Marshal(envelope)
+	} else {
+		value, err = proto.Marshal(envelope)
+	}
+
+	if err != nil {
+		sink.logger.Errorf("Error encoding envelope for Kafka: %s", err)
+		atomic.AddUint64(&sink.failed, 1)
+		return
+	}
+
+	sink.publish(webserver.CreateEnvelopeKey(envelope), value)
+}
+
+//SendResources publishes cached resources when the sink runs in resources mode
+func (sink *KafkaSink) SendResources(snapshot map[string][]webserver.Resource) {
+	if sink.config.Mode != ModeResources {
+		return
+	}
+
+	for origin, resources := range snapshot {
+		for _, resource := range resources {
+			value, err := json.Marshal(resourceMessage{origin, resource})
+			if err != nil {
+				sink.logger.Errorf("Error encoding resource for Kafka: %s", err)
+				atomic.AddUint64(&sink.failed, 1)
+				continue
+			}
+
+			sink.publish(webserver.CreateResourceKey(resource), value)
+		}
+	}
+}
+
+//Metrics returns the delivery counts of the sink
+func (sink *KafkaSink) Metrics() DeliveryMetrics {
+	return DeliveryMetrics{
+		Sent:      atomic.LoadUint64(&sink.sent),
+		Delivered: atomic.LoadUint64(&sink.delivered),
+		Failed:    atomic.LoadUint64(&sink.failed),
+		Dropped:   atomic.LoadUint64(&sink.dropped),
+	}
+}
+
+//Close flushes buffered messages and closes the producer
+func (sink *KafkaSink) Close() error {
+	close(sink.messages)
+	sink.wait.Wait()
+
+	metrics := sink.Metrics()
+	sink.logger.Infof("Closed Kafka sink after sending %d, delivering %d, failing %d and dropping %d messages",
+		metrics.Sent, metrics.Delivered, metrics.Failed, metrics.Dropped)
+	return nil
+}
+
+//publish never blocks the firehose, messages are dropped while the buffer is full
+func (sink *KafkaSink) publish(key string, value []byte) {
+	message := &sarama.ProducerMessage{
+		Topic: sink.config.Topic,
+		Key:   sarama.StringEncoder(key),
+		Value: sarama.ByteEncoder(value),
+	}
+
+	select {
+	case sink.messages <- message:
+		atomic.AddUint64(&sink.sent, 1)
+	default:
+		atomic.AddUint64(&sink.dropped, 1)
+		sink.logger.Debugf("Dropped Kafka message with key %s", key)
+	}
+}
+
+func (sink *KafkaSink) forwardMessages() {
+	defer sink.wait.Done()
+	for message := range sink.messages {
+		sink.producer.Input() <- message
+	}
+
+	sink.producer.AsyncClose()
+}
+
+func (sink *KafkaSink) readSuccesses() {
+	defer sink.wait.Done()
+	for range sink.producer.Successes() {
+		atomic.AddUint64(&sink.delivered, 1)
+	}
+}
+
+func (sink *KafkaSink) readErrors() {
+	defer sink.wait.Done()
+	for err := range sink.producer.Errors() {
+		atomic.AddUint64(&sink.failed, 1)
+		sink.logger.Errorf("Error publishing to Kafka: %s", err.Error())
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package kafkasink
+
+import (
+	"testing"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+	"github.com/Shopify/sarama"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	sinkLogFile         = "bm_kafka_sink.log"
+	sinkLogName         = "bm_kafka_sink"
+	sinkLogLevel        = "debug"
+
+	testTopic = "firehose"
+)
+
+func TestEnvelopeMode(t *testing.T) {
+	broker := createFakeBroker(t)
+	defer broker.Close()
+
+	sink := createSink(t, broker, ModeEnvelopes, EncodingProtobuf)
+
+	t.Log("Sending envelope to sink...")
+	sink.SendEnvelope(createEnvelope())
+	sink.SendResources(createSnapshot())
+
+	err := sink.Close()
+	if err != nil {
+		t.Fatalf("Error closing sink: %s", err.Error())
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Sent: 1, Delivered: 1})
+}
+
+func TestResourceMode(t *testing.T) {
+	broker := createFakeBroker(t)
+	defer broker.Close()
+
+	sink := createSink(t, broker, ModeResources, EncodingJSON)
+
+	t.Log("Sending resources to sink...")
+	sink.SendEnvelope(createEnvelope())
+	sink.SendResources(createSnapshot())
+
+	err := sink.Close()
+	if err != nil {
+		t.Fatalf("Error closing sink: %s", err.Error())
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Sent: 2, Delivered: 2})
+}
+
+func TestResourceModeDefaultEncoding(t *testing.T) {
+	broker := createFakeBroker(t)
+	defer broker.Close()
+
+	sink := createSink(t, broker, ModeResources, "")
+	defer sink.Close()
+
+	t.Logf("Checking encoding of resources mode without encoding... (expected value: %s)", EncodingJSON)
+	if sink.config.Encoding != EncodingJSON {
+		t.Errorf("Expected encoding %s, but received %s", EncodingJSON, sink.config.Encoding)
+	}
+}
+
+func TestFailedDelivery(t *testing.T) {
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(testTopic, 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).
+			SetError(testTopic, 0, sarama.ErrMessageSizeTooLarge),
+	})
+
+	sink := createSink(t, broker, ModeEnvelopes, EncodingJSON)
+
+	t.Log("Sending envelope to failing broker...")
+	sink.SendEnvelope(createEnvelope())
+
+	err := sink.Close()
+	if err != nil {
+		t.Fatalf("Error closing sink: %s", err.Error())
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Sent: 1, Failed: 1})
+}
+
+func TestBadConfiguration(t *testing.T) {
+	t.Log("Checking protobuf encoding in resources mode... (expecting error)")
+	config := &nozzleconfiguration.KafkaConfiguration{
+		Brokers:  []string{"localhost:9092"},
+		Topic:    testTopic,
+		Mode:     ModeResources,
+		Encoding: EncodingProtobuf,
+	}
+
+	_, err := New(config, createLogger())
+	if err == nil {
+		t.Errorf("Expected error creating sink with protobuf resources, but sink was created")
+	}
+
+	t.Log("Checking missing brokers... (expecting error)")
+	config = &nozzleconfiguration.KafkaConfiguration{Topic: testTopic}
+
+	_, err = New(config, createLogger())
+	if err == nil {
+		t.Errorf("Expected error creating sink without brokers, but sink was created")
+	}
+}
+
+func checkMetrics(t *testing.T, sink *KafkaSink, expected DeliveryMetrics) {
+	t.Logf("Checking delivery metrics... (expected value: %+v)", expected)
+	if metrics := sink.Metrics(); metrics != expected {
+		t.Errorf("Expected delivery metrics %+v, but received %+v", expected, metrics)
+	}
+}
+
+func createFakeBroker(t *testing.T) *sarama.MockBroker {
+	t.Log("Creating fake Kafka broker...")
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(testTopic, 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t),
+	})
+
+	return broker
+}
+
+func createSink(t *testing.T, broker *sarama.MockBroker, mode string, encoding string) *KafkaSink {
+	config := &nozzleconfiguration.KafkaConfiguration{
+		Enabled:  true,
+		Brokers:  []string{broker.Addr()},
+		Topic:    testTopic,
+		Mode:     mode,
+		Encoding: encoding,
+	}
+
+	sink, err := New(config, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating Kafka sink: %s", err.Error())
+	}
+
+	return sink
+}
+
+func createLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, sinkLogFile, sinkLogName, sinkLogLevel)
+}
+
+func createEnvelope() *events.Envelope {
+	origin := "gorouter"
+	deployment := "deployment"
+	eventType := events.Envelope_ValueMetric
+	job := "job"
+	index := "0"
+	ip := "127.0.0.1"
+	metricName := "metric"
+	value := float64(100)
+	unit := "unit"
+
+	return &events.Envelope{
+		Origin:     &origin,
+		EventType:  &eventType,
+		Deployment: &deployment,
+		Job:        &job,
+		Index:      &index,
+		Ip:         &ip,
+		ValueMetric: &events.ValueMetric{
+			Name:  &metricName,
+			Value: &value,
+			Unit:  &unit,
+		},
+	}
+}
+
+func createSnapshot() map[string][]webserver.Resource {
+	resource := webserver.Resource{
+		Deployment:     "deployment",
+		Job:            "job",
+		Index:          "0",
+		IP:             "127.0.0.1",
+		ValueMetrics:   map[string]float64{"metric": 100},
+		CounterMetrics: map[string]float64{},
+	}
+
+	return map[string][]webserver.Resource{
+		"gorouter": {resource},
+		"rep":      {resource},
+	}
+}
@@ -4,12 +4,18 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/bluemedorafirehosenozzle"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+	"github.com/cloudfoundry/gosteno"
 )
 
 const (
@@ -56,10 +62,22 @@ func normalSetup() {
 	server := createWebServer(config)
 
 	nozzle := bluemedorafirehosenozzle.New(config, server, logger)
-	nozzle.Start()
+	err = nozzle.Start()
 
-	if err != nil {
-		logger.Fatalf("Error while running nozzle: %s", err.Error())
+	os.Exit(exitCode(err, logger))
+}
+
+//exitCode is 0 after a graceful shutdown, 2 when the shutdown timed out and 1 for other errors
+func exitCode(err error, logger *gosteno.Logger) int {
+	switch err {
+	case nil:
+		return 0
+	case bluemedorafirehosenozzle.ErrShutdownTimeout:
+		logger.Error("Nozzle did not shut down in time")
+		return 2
+	default:
+		logger.Errorf("Error while running nozzle: %s", err.Error())
+		return 1
 	}
 }
 
@@ -74,15 +92,46 @@ func standUpWebServer() {
     
     server := webserver.New(config, logger)
     
+    signals := make(chan os.Signal, 1)
+    signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+    
     logger.Info("Starting webserver")
     errors := server.Start(webserver.DefaultKeyLocation, webserver.DefaultCertLocation)
     
     select {
-        case err := <-errors:
-            logger.Fatalf("Error while running server: %s", err.Error())
+        case err, ok := <-errors:
+            if ok {
+                logger.Fatalf("Error while running server: %s", err.Error())
+            }
+        case sig := <-signals:
+            os.Exit(exitCode(shutdownWebServer(server, config, sig, logger), logger))
     }
 }
 
+//shutdownWebServer drains active requests within Shutdown.TimeoutSeconds like the nozzle does
+func shutdownWebServer(server *webserver.WebServer, config *nozzleconfiguration.NozzleConfiguration, sig os.Signal, logger *gosteno.Logger) error {
+	timeoutSeconds := config.Shutdown.TimeoutSeconds
+	if timeoutSeconds == 0 {
+		timeoutSeconds = bluemedorafirehosenozzle.DefaultShutdownTimeoutSeconds
+	}
+	
+	timeout := time.Duration(timeoutSeconds) * time.Second
+	logger.Infof("Received %s, shutting down within %s", sig, timeout)
+	
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	
+	err := server.Shutdown(ctx)
+	if err == context.DeadlineExceeded {
+		return bluemedorafirehosenozzle.ErrShutdownTimeout
+	}
+	
+	if err == nil {
+		logger.Info("Shutdown complete")
+	}
+	return err
+}
+
 func createWebServer(config *nozzleconfiguration.NozzleConfiguration) *webserver.WebServer {
 	logger := logger.New(defaultLogDirectory, webserverLogFile, webserverLogName, *logLevel)
 	return webserver.New(config, logger)
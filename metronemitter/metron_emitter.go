@@ -0,0 +1,147 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package metronemitter
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/gogo/protobuf/proto"
+)
+
+//Metron emitter defaults, Metron accepts dropsonde envelopes on UDP port 3457
+const (
+	DefaultAddress         = "127.0.0.1:3457"
+	DefaultIntervalSeconds = 10
+)
+
+//DeliveryMetrics counts envelopes handled by the emitter since it was created
+type DeliveryMetrics struct {
+	Sent   uint64
+	Failed uint64
+}
+
+//MetronEmitter periodically sends the nozzle's own metrics to the local Metron agent as dropsonde
+//ValueMetric and CounterEvent envelopes
+type MetronEmitter struct {
+	//Counters are kept first to stay 64-bit aligned for atomic access
+	sent   uint64
+	failed uint64
+
+	config   *nozzleconfiguration.MetronConfiguration
+	source   stats.Source
+	logger   *gosteno.Logger
+	conn     net.Conn
+	snapshot func() stats.Snapshot
+	previous stats.Snapshot
+	mutex    sync.Mutex
+	done     chan struct{}
+	stopped  chan struct{}
+}
+
+//New creates a MetronEmitter sending the snapshots returned by snapshot to the configured address as envelopes of
+//source, it starts emitting every IntervalSeconds right away. The configured Origin is used when source has none
+func New(config *nozzleconfiguration.MetronConfiguration, source stats.Source, snapshot func() stats.Snapshot, logger *gosteno.Logger) (*MetronEmitter, error) {
+	if config.Address == "" {
+		config.Address = DefaultAddress
+	}
+
+	if config.Origin == "" {
+		config.Origin = stats.DefaultOrigin
+	}
+
+	if config.IntervalSeconds == 0 {
+		config.IntervalSeconds = DefaultIntervalSeconds
+	}
+
+	if source.Origin == "" {
+		source.Origin = config.Origin
+	}
+
+	conn, err := net.Dial("udp", config.Address)
+	if err != nil {
+		return nil, fmt.Errorf("Error connecting to Metron at %s: %s", config.Address, err)
+	}
+
+	emitter := &MetronEmitter{
+		config:   config,
+		source:   source,
+		logger:   logger,
+		conn:     conn,
+		snapshot: snapshot,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+
+	go emitter.run()
+
+	logger.Infof("Emitting nozzle metrics with origin %s to Metron at %s every %d seconds", source.Origin, config.Address, config.IntervalSeconds)
+	return emitter, nil
+}
+
+//Emit sends the current snapshot, counter events carry the delta since the previous Emit
+func (emitter *MetronEmitter) Emit() {
+	emitter.mutex.Lock()
+	defer emitter.mutex.Unlock()
+
+	snapshot := emitter.snapshot()
+	envelopes := snapshot.Envelopes(emitter.source, emitter.previous, time.Now())
+	emitter.previous = snapshot
+
+	for _, envelope := range envelopes {
+		message, err := proto.Marshal(envelope)
+		if err == nil {
+			_, err = emitter.conn.Write(message)
+		}
+
+		if err != nil {
+			atomic.AddUint64(&emitter.failed, 1)
+			emitter.logger.Debugf("Error emitting %s to Metron: %s", envelope.GetEventType(), err.Error())
+			continue
+		}
+		atomic.AddUint64(&emitter.sent, 1)
+	}
+}
+
+//Metrics returns the delivery counts of the emitter
+func (emitter *MetronEmitter) Metrics() DeliveryMetrics {
+	return DeliveryMetrics{
+		Sent:   atomic.LoadUint64(&emitter.sent),
+		Failed: atomic.LoadUint64(&emitter.failed),
+	}
+}
+
+//Close emits a last snapshot and closes the connection
+func (emitter *MetronEmitter) Close() error {
+	close(emitter.done)
+	<-emitter.stopped
+
+	emitter.Emit()
+
+	metrics := emitter.Metrics()
+	emitter.logger.Infof("Closed Metron emitter after sending %d and failing %d envelopes", metrics.Sent, metrics.Failed)
+	return emitter.conn.Close()
+}
+
+func (emitter *MetronEmitter) run() {
+	defer close(emitter.stopped)
+
+	ticker := time.NewTicker(time.Duration(emitter.config.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			emitter.Emit()
+		case <-emitter.done:
+			return
+		}
+	}
+}
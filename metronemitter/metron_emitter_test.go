@@ -0,0 +1,143 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package metronemitter
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+	"github.com/gogo/protobuf/proto"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	emitterLogFile      = "bm_metron_emitter.log"
+	emitterLogName      = "bm_metron_emitter"
+	emitterLogLevel     = "debug"
+
+	testOrigin = "test-nozzle"
+	testIndex  = "1"
+	testIP     = "10.0.0.1"
+)
+
+func TestEmit(t *testing.T) {
+	metron := listenUDP(t)
+	defer metron.Close()
+
+	registry := stats.New()
+	registry.Add(stats.EnvelopesReceived, 5)
+	registry.Set(stats.CacheResources, 2)
+
+	emitter := createEmitter(t, metron, registry, 60)
+	defer emitter.Close()
+
+	emitter.Emit()
+	envelopes := readEnvelopes(t, metron, 2)
+
+	valueMetric, counterEvent := envelopes[0], envelopes[1]
+	t.Logf("Check if gauge is emitted as value metric... (expecting %s of 2 with origin %s)", stats.CacheResources, testOrigin)
+	if valueMetric.GetOrigin() != testOrigin || valueMetric.GetValueMetric().GetName() != stats.CacheResources || valueMetric.GetValueMetric().GetValue() != 2 {
+		t.Errorf("Expected value metric %s of 2 with origin %s, but received %v", stats.CacheResources, testOrigin, valueMetric)
+	}
+
+	t.Logf("Check if envelopes identify the nozzle instance... (expecting job %s, index %s and IP %s)", testOrigin, testIndex, testIP)
+	if valueMetric.GetJob() != testOrigin || valueMetric.GetIndex() != testIndex || valueMetric.GetIp() != testIP {
+		t.Errorf("Expected job %s, index %s and IP %s, but received %v", testOrigin, testIndex, testIP, valueMetric)
+	}
+
+	t.Logf("Check if counter is emitted as counter event... (expecting %s with delta 5)", stats.EnvelopesReceived)
+	if counterEvent.GetCounterEvent().GetName() != stats.EnvelopesReceived || counterEvent.GetCounterEvent().GetDelta() != 5 {
+		t.Errorf("Expected counter event %s with delta 5, but received %v", stats.EnvelopesReceived, counterEvent)
+	}
+
+	registry.Add(stats.EnvelopesReceived, 3)
+	emitter.Emit()
+	counterEvent = readEnvelopes(t, metron, 2)[1]
+
+	t.Log("Check if next counter event carries the delta since the last emit... (expecting delta 3 and total 8)")
+	if counterEvent.GetCounterEvent().GetDelta() != 3 || counterEvent.GetCounterEvent().GetTotal() != 8 {
+		t.Errorf("Expected counter event with delta 3 and total 8, but received %v", counterEvent)
+	}
+
+	t.Log("Check if delivery is counted... (expecting 4 sent)")
+	if metrics := emitter.Metrics(); metrics.Sent != 4 || metrics.Failed != 0 {
+		t.Errorf("Expected 4 sent and 0 failed, but received %+v", metrics)
+	}
+}
+
+func TestEmitOnInterval(t *testing.T) {
+	metron := listenUDP(t)
+	defer metron.Close()
+
+	registry := stats.New()
+	registry.Increment(stats.Flushes)
+
+	emitter := createEmitter(t, metron, registry, 1)
+	defer emitter.Close()
+
+	t.Log("Check if the emitter sends on its own every interval... (expecting 1 envelope)")
+	envelope := readEnvelopes(t, metron, 1)[0]
+	if envelope.GetCounterEvent().GetName() != stats.Flushes {
+		t.Errorf("Expected counter event %s, but received %v", stats.Flushes, envelope)
+	}
+}
+
+func createEmitter(t *testing.T, metron *net.UDPConn, registry *stats.Registry, intervalSeconds uint32) *MetronEmitter {
+	config := &nozzleconfiguration.MetronConfiguration{
+		Enabled:         true,
+		Address:         metron.LocalAddr().String(),
+		Origin:          testOrigin,
+		IntervalSeconds: intervalSeconds,
+	}
+
+	source := stats.Source{Job: testOrigin, Index: testIndex, IP: testIP}
+	emitter, err := New(config, source, registry.Snapshot, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating Metron emitter: %s", err.Error())
+	}
+
+	return emitter
+}
+
+func listenUDP(t *testing.T) *net.UDPConn {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("Error listening on UDP: %s", err.Error())
+	}
+
+	return conn
+}
+
+func readEnvelopes(t *testing.T, conn *net.UDPConn, count int) []*events.Envelope {
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	envelopes := make([]*events.Envelope, 0, count)
+	buffer := make([]byte, 65535)
+	for len(envelopes) < count {
+		read, err := conn.Read(buffer)
+		if err != nil {
+			t.Fatalf("Expected %d envelopes, but received %d and error %s", count, len(envelopes), err.Error())
+		}
+
+		envelope := &events.Envelope{}
+		err = proto.Unmarshal(buffer[:read], envelope)
+		if err != nil {
+			t.Fatalf("Error decoding envelope: %s", err.Error())
+		}
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes
+}
+
+func createLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, emitterLogFile, emitterLogName, emitterLogLevel)
+}
@@ -26,6 +26,8 @@ const (
 	metricCacheDurationSecondsEnv = "BM_METRIC_CACHE_DURATION_SECONDS"
 	webServerPortEnv              = "PORT"
 	webServerUseSSLENV            = "BM_WEBSERVER_USE_SSL"
+	checkAPIKeyEnv                = "BM_CHECK_API_KEY"
+	webServerBindAddressEnv       = "BM_WEBSERVER_BIND_ADDRESS"
 )
 
 //NozzleConfiguration represents configuration file
@@ -40,7 +42,185 @@ type NozzleConfiguration struct {
 	IdleTimeoutSeconds         uint32
 	MetricCacheDurationSeconds uint32
 	WebServerPort              uint32
+	WebServerBindAddress       string
 	WebServerUseSSL			   bool
+	CheckAPIKey                string
+	TokenIdleTimeoutSeconds    uint32
+	TokenAbsoluteTimeoutSeconds uint32
+	Kafka                      KafkaConfiguration
+	Syslog                     SyslogConfiguration
+	Webhook                    WebhookConfiguration
+	Alerting                   AlertingConfiguration
+	APIAuthentication          APIAuthenticationConfiguration
+	APIClients                 []APIClientConfiguration
+	EndpointGroups             map[string][]string
+	RateLimit                  RateLimitConfiguration
+	ClientCertificates         ClientCertificateConfiguration
+	CertificateReloadSeconds   uint32
+	HTTPServer                 HTTPServerConfiguration
+	Listeners                  []ListenerConfiguration
+	Shutdown                   ShutdownConfiguration
+	SelfSignedCertificate      SelfSignedCertificateConfiguration
+	Audit                      AuditConfiguration
+	ReadinessWindowSeconds     uint32
+	Metron                     MetronConfiguration
+}
+
+//KafkaConfiguration represents the Kafka sink section of the configuration file
+type KafkaConfiguration struct {
+	Enabled               bool
+	Brokers               []string
+	Topic                 string
+	Mode                  string
+	Encoding              string
+	TLSEnabled            bool
+	TLSCAFile             string
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSInsecureSkipVerify bool
+	SASLUsername          string
+	SASLPassword          string
+}
+
+//SyslogConfiguration represents the syslog sink section of the configuration file
+type SyslogConfiguration struct {
+	Enabled               bool
+	Protocol              string
+	Address               string
+	Hostname              string
+	TLSCAFile             string
+	TLSInsecureSkipVerify bool
+	AppGUIDs              []string
+	EventTypes            []string
+	MessageTypes          []string
+	QueueSize             int
+}
+
+//WebhookConfiguration represents the webhook sink section of the configuration file
+type WebhookConfiguration struct {
+	Enabled                  bool
+	URL                      string
+	Method                   string
+	Template                 string
+	Headers                  map[string]string
+	BearerToken              string
+	BasicAuthUsername        string
+	BasicAuthPassword        string
+	BatchSize                int
+	TimeoutSeconds           uint32
+	MaxRetries               int
+	RetryBackoffMilliseconds uint32
+	InsecureSSLSkipVerify    bool
+}
+
+//AlertingConfiguration represents the alerting section of the configuration file
+type AlertingConfiguration struct {
+	Enabled                   bool
+	EvaluationIntervalSeconds uint32
+	WebhookURLs               []string
+	Rules                     []AlertRuleConfiguration
+}
+
+//AlertRuleConfiguration represents a single threshold rule evaluated against cached metrics
+type AlertRuleConfiguration struct {
+	Name       string
+	Origin     string
+	Metric     string
+	Job        string
+	Comparator string
+	Threshold  float64
+	ForSeconds uint32
+	Severity   string
+}
+
+//APIAuthenticationConfiguration represents how /token logins are validated against UAA
+type APIAuthenticationConfiguration struct {
+	GrantType             string
+	ClientID              string
+	ClientSecret          string
+	RequiredScope         string
+	CacheSeconds          uint32
+	AcceptBearerTokens    bool
+	Audience              string
+	Issuer                string
+	TokenKeysCacheSeconds uint32
+}
+
+//APIClientConfiguration represents an API consumer that may only read the listed endpoints, origins or endpoint
+//groups, it logs in with its own secret if one is set
+type APIClientConfiguration struct {
+	Name         string
+	SecretSHA256 string
+	Endpoints    []string
+}
+
+//RateLimitConfiguration represents the rate limits and login lockouts of the RESTful API per client IP and username
+type RateLimitConfiguration struct {
+	Enabled           bool
+	RequestsPerSecond float64
+	Burst             int
+	MaxFailures       int
+	LockoutSeconds    uint32
+	MaxLockoutSeconds uint32
+}
+
+//ClientCertificateConfiguration represents client certificate authentication of the RESTful API
+type ClientCertificateConfiguration struct {
+	Enabled      bool
+	CAFile       string
+	AllowedNames []string
+	RequireToken bool
+}
+
+//HTTPServerConfiguration represents the TLS policy and limits of the web server
+type HTTPServerConfiguration struct {
+	MinTLSVersion            string
+	CipherSuites             []string
+	ReadTimeoutSeconds       uint32
+	ReadHeaderTimeoutSeconds uint32
+	WriteTimeoutSeconds      uint32
+	IdleTimeoutSeconds       uint32
+	MaxHeaderBytes           int
+}
+
+//ListenerConfiguration represents an address the web server listens on and the routes it exposes there
+type ListenerConfiguration struct {
+	Name                  string
+	BindAddress           string
+	Port                  uint32
+	UseSSL                bool
+	DisableAuthentication bool
+	Internal              bool
+	Routes                []string
+}
+
+//ShutdownConfiguration represents the graceful shutdown on SIGTERM and SIGINT
+type ShutdownConfiguration struct {
+	TimeoutSeconds uint32
+	StateFile      string
+}
+
+//SelfSignedCertificateConfiguration represents the certificate generated when the web server has none
+type SelfSignedCertificateConfiguration struct {
+	Enabled   bool
+	Hosts     []string
+	ValidDays uint32
+}
+
+//AuditConfiguration represents the audit log of RESTful API calls
+type AuditConfiguration struct {
+	Enabled    bool
+	Output     string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+//MetronConfiguration represents emitting the nozzle's own metrics to the local Metron agent
+type MetronConfiguration struct {
+	Enabled         bool
+	Address         string
+	Origin          string
+	IntervalSeconds uint32
 }
 
 //New NozzleConfiguration
@@ -70,6 +250,8 @@ func New(configPath string, logger *gosteno.Logger) (*NozzleConfiguration, error
 	overrideWithEnvUint32(metricCacheDurationSecondsEnv, &nozzleConfig.MetricCacheDurationSeconds)
 	overrideWithEnvUint32(webServerPortEnv, &nozzleConfig.WebServerPort)
 	overrideWithEnvBool(webServerUseSSLENV, &nozzleConfig.WebServerUseSSL)
+	overrideWithEnvVar(checkAPIKeyEnv, &nozzleConfig.CheckAPIKey)
+	overrideWithEnvVar(webServerBindAddressEnv, &nozzleConfig.WebServerBindAddress)
 
 	logger.Debug(fmt.Sprintf("Loaded configuration to UAAURL <%s>, UAA Username <%s>, Traffic Controller URL <%s>, Disable Access Control <%v>, Insecure SSL Skip Verify <%v>",
 		nozzleConfig.UAAURL, nozzleConfig.UAAUsername, nozzleConfig.TrafficControllerURL, nozzleConfig.DisableAccessControl, nozzleConfig.InsecureSSLSkipVerify))
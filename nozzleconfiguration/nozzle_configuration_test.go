@@ -35,6 +35,8 @@ const (
     testMetricCacheDuration = uint32(60)
     testWebServerPort = uint32(8081)
     testWebServerUseSSL = true
+    testKafkaTopic = "firehose"
+    testKafkaBroker = "localhost:9092"
 
     testEnvUAAURL = "env_UAAURL"
     testEnvUsername = "env_username"
@@ -125,6 +127,16 @@ func TestConfigParsing(t *testing.T) {
     if config.WebServerUseSSL != testWebServerUseSSL {
         t.Errorf("Expected Web Server Port of %v, but received %v", testWebServerUseSSL, config.WebServerPort)
     }
+
+    t.Log(fmt.Sprintf("Checking Kafka Topic... (expected value: %s)", testKafkaTopic))
+    if config.Kafka.Topic != testKafkaTopic {
+        t.Errorf("Expected Kafka Topic of %s, but received %s", testKafkaTopic, config.Kafka.Topic)
+    }
+
+    t.Log(fmt.Sprintf("Checking Kafka Brokers... (expected value: [%s])", testKafkaBroker))
+    if len(config.Kafka.Brokers) != 1 || config.Kafka.Brokers[0] != testKafkaBroker {
+        t.Errorf("Expected Kafka Brokers of [%s], but received %v", testKafkaBroker, config.Kafka.Brokers)
+    }
     
     err = tearDownEnvironment(t)
     if err != nil {
@@ -330,11 +342,23 @@ func createGoodConfigFile(t *testing.T) error {
     t.Log("Creating good config file...")
     
     message := NozzleConfiguration{
-        testUAAURL, testUsername, 
-        testPassword, testTrafficControllerURL, testSubscriptionID,
-        testDisableAccessControl, testInsecureSSLSkipVerify, 
-        testIdleTimeout, testMetricCacheDuration,
-        testWebServerPort, testWebServerUseSSL}
+        UAAURL:                     testUAAURL,
+        UAAUsername:                testUsername,
+        UAAPassword:                testPassword,
+        TrafficControllerURL:       testTrafficControllerURL,
+        SubscriptionID:             testSubscriptionID,
+        DisableAccessControl:       testDisableAccessControl,
+        InsecureSSLSkipVerify:      testInsecureSSLSkipVerify,
+        IdleTimeoutSeconds:         testIdleTimeout,
+        MetricCacheDurationSeconds: testMetricCacheDuration,
+        WebServerPort:              testWebServerPort,
+        WebServerUseSSL:            testWebServerUseSSL,
+        Kafka: KafkaConfiguration{
+            Enabled: true,
+            Brokers: []string{testKafkaBroker},
+            Topic:   testKafkaTopic,
+        },
+    }
         
     messageBytes, _ := json.Marshal(message)
     
@@ -0,0 +1,216 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+const (
+	defaultRequestsPerSecond = 10
+	defaultBurst             = 50
+	defaultMaxFailures       = 5
+	defaultLockoutSeconds    = 30
+	defaultMaxLockoutSeconds = 3600
+
+	pruneInterval = time.Minute
+)
+
+//Stats are the counters of a Limiter since it was created
+type Stats struct {
+	Allowed     uint64
+	RateLimited uint64
+	LockedOut   uint64
+	Failures    uint64
+	Lockouts    uint64
+	TrackedKeys int
+}
+
+//keyState is the token bucket and failure history of one key
+type keyState struct {
+	tokens      float64
+	updated     time.Time
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+//Limiter limits requests per key with token buckets and locks keys out after repeated failures
+type Limiter struct {
+	config     *nozzleconfiguration.RateLimitConfiguration
+	now        func() time.Time
+	lockout    time.Duration
+	maxLockout time.Duration
+
+	mutex    sync.Mutex
+	entries  map[string]*keyState
+	stats    Stats
+	prunedAt time.Time
+}
+
+//New creates a Limiter, now defaults to time.Now
+func New(config *nozzleconfiguration.RateLimitConfiguration, now func() time.Time) *Limiter {
+	if config.RequestsPerSecond <= 0 {
+		config.RequestsPerSecond = defaultRequestsPerSecond
+	}
+
+	if config.Burst <= 0 {
+		config.Burst = defaultBurst
+	}
+
+	if config.MaxFailures <= 0 {
+		config.MaxFailures = defaultMaxFailures
+	}
+
+	if config.LockoutSeconds == 0 {
+		config.LockoutSeconds = defaultLockoutSeconds
+	}
+
+	if config.MaxLockoutSeconds < config.LockoutSeconds {
+		config.MaxLockoutSeconds = defaultMaxLockoutSeconds
+	}
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Limiter{
+		config:     config,
+		now:        now,
+		lockout:    time.Duration(config.LockoutSeconds) * time.Second,
+		maxLockout: time.Duration(config.MaxLockoutSeconds) * time.Second,
+		entries:    make(map[string]*keyState),
+		prunedAt:   now(),
+	}
+}
+
+//Allow takes a request of key from its bucket, when it is denied the returned duration is when to retry
+func (limiter *Limiter) Allow(key string) (bool, time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	limiter.prune(now)
+	entry := limiter.entry(key, now)
+
+	if now.Before(entry.lockedUntil) {
+		limiter.stats.LockedOut++
+		return false, entry.lockedUntil.Sub(now)
+	}
+
+	entry.tokens += now.Sub(entry.updated).Seconds() * limiter.config.RequestsPerSecond
+	if entry.tokens > float64(limiter.config.Burst) {
+		entry.tokens = float64(limiter.config.Burst)
+	}
+	entry.updated = now
+
+	if entry.tokens < 1 {
+		limiter.stats.RateLimited++
+		return false, time.Duration((1 - entry.tokens) / limiter.config.RequestsPerSecond * float64(time.Second))
+	}
+
+	entry.tokens--
+	limiter.stats.Allowed++
+	return true, 0
+}
+
+//Lockout returns how long key is still locked out, unlike Allow it does not take a request from the bucket of key
+func (limiter *Limiter) Lockout(key string) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	entry, ok := limiter.entries[key]
+	if !ok || !now.Before(entry.lockedUntil) {
+		return 0
+	}
+
+	limiter.stats.LockedOut++
+	return entry.lockedUntil.Sub(now)
+}
+
+//Failure records a failed attempt of key. From MaxFailures failures on the key is locked out, starting with
+//LockoutSeconds and doubling with every further failure up to MaxLockoutSeconds. The lockout is returned
+func (limiter *Limiter) Failure(key string) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := limiter.now()
+	entry := limiter.entry(key, now)
+
+	//Failures are forgotten once they are older than the longest lockout
+	if now.Sub(entry.lastFailure) > limiter.maxLockout {
+		entry.failures = 0
+	}
+
+	entry.failures++
+	entry.lastFailure = now
+	limiter.stats.Failures++
+
+	if entry.failures < limiter.config.MaxFailures {
+		return 0
+	}
+
+	lockout := limiter.lockout
+	for i := limiter.config.MaxFailures; i < entry.failures && lockout < limiter.maxLockout; i++ {
+		lockout *= 2
+	}
+
+	if lockout > limiter.maxLockout {
+		lockout = limiter.maxLockout
+	}
+
+	entry.lockedUntil = now.Add(lockout)
+	limiter.stats.Lockouts++
+	return lockout
+}
+
+//Success forgets the failures of key
+func (limiter *Limiter) Success(key string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if entry, ok := limiter.entries[key]; ok {
+		entry.failures = 0
+	}
+}
+
+//Stats returns the current counters
+func (limiter *Limiter) Stats() Stats {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	stats := limiter.stats
+	stats.TrackedKeys = len(limiter.entries)
+	return stats
+}
+
+//entry must be called with the mutex held
+func (limiter *Limiter) entry(key string, now time.Time) *keyState {
+	existing, ok := limiter.entries[key]
+	if !ok {
+		existing = &keyState{tokens: float64(limiter.config.Burst), updated: now}
+		limiter.entries[key] = existing
+	}
+
+	return existing
+}
+
+//prune must be called with the mutex held, it forgets keys with a full bucket and no failures to remember
+func (limiter *Limiter) prune(now time.Time) {
+	if now.Sub(limiter.prunedAt) < pruneInterval {
+		return
+	}
+	limiter.prunedAt = now
+
+	refill := time.Duration(float64(limiter.config.Burst) / limiter.config.RequestsPerSecond * float64(time.Second))
+	for key, entry := range limiter.entries {
+		if now.Sub(entry.updated) >= refill && now.Sub(entry.lastFailure) > limiter.maxLockout && !now.Before(entry.lockedUntil) {
+			delete(limiter.entries, key)
+		}
+	}
+}
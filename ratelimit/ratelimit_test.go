@@ -0,0 +1,120 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+	return clock.now
+}
+
+func TestRateLimit(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	limiter := New(&nozzleconfiguration.RateLimitConfiguration{RequestsPerSecond: 2, Burst: 3}, clock.Now)
+
+	t.Log("Checking burst of 3 requests is allowed...")
+	for i := 0; i < 3; i++ {
+		if allowed, _ := limiter.Allow("ip 10.0.0.1"); !allowed {
+			t.Fatalf("Expected request %d allowed, but was denied", i)
+		}
+	}
+
+	t.Log("Checking fourth request is denied... (expected retry after: 500ms)")
+	allowed, retryAfter := limiter.Allow("ip 10.0.0.1")
+	if allowed || retryAfter != 500*time.Millisecond {
+		t.Errorf("Expected denied request with retry after 500ms, but received allowed %v and retry after %v", allowed, retryAfter)
+	}
+
+	t.Log("Checking other keys are not limited...")
+	if allowed, _ := limiter.Allow("ip 10.0.0.2"); !allowed {
+		t.Errorf("Expected request of other key allowed, but was denied")
+	}
+
+	t.Log("Checking bucket refills...")
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	if allowed, _ := limiter.Allow("ip 10.0.0.1"); !allowed {
+		t.Errorf("Expected request allowed after refill, but was denied")
+	}
+
+	stats := limiter.Stats()
+	if stats.Allowed != 5 || stats.RateLimited != 1 || stats.TrackedKeys != 2 {
+		t.Errorf("Expected 5 allowed, 1 rate limited and 2 tracked keys, but received %+v", stats)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	limiter := New(&nozzleconfiguration.RateLimitConfiguration{MaxFailures: 3, LockoutSeconds: 10, MaxLockoutSeconds: 30}, clock.Now)
+
+	t.Log("Checking failures below MaxFailures do not lock out...")
+	for i := 0; i < 2; i++ {
+		if lockout := limiter.Failure("user admin"); lockout != 0 {
+			t.Fatalf("Expected no lockout after %d failures, but received %v", i+1, lockout)
+		}
+	}
+
+	expectedLockouts := []time.Duration{10 * time.Second, 20 * time.Second, 30 * time.Second, 30 * time.Second}
+	for _, expectedLockout := range expectedLockouts {
+		lockout := limiter.Failure("user admin")
+		t.Logf("Checking lockout doubles up to the maximum... (expected lockout: %v)", expectedLockout)
+		if lockout != expectedLockout {
+			t.Errorf("Expected lockout %v, but received %v", expectedLockout, lockout)
+		}
+
+		allowed, retryAfter := limiter.Allow("user admin")
+		if allowed || retryAfter != expectedLockout {
+			t.Errorf("Expected denied request with retry after %v, but received allowed %v and retry after %v", expectedLockout, allowed, retryAfter)
+		}
+
+		clock.now = clock.now.Add(lockout)
+	}
+
+	t.Log("Checking lockout is over once it has passed... (expected lockout: 0s)")
+	if lockout := limiter.Lockout("user admin"); lockout != 0 {
+		t.Errorf("Expected no lockout, but received %v", lockout)
+	}
+
+	limiter.Failure("user admin")
+	t.Log("Checking lockout is reported without taking a request... (expected lockout: 30s)")
+	if lockout := limiter.Lockout("user admin"); lockout != 30*time.Second {
+		t.Errorf("Expected lockout 30s, but received %v", lockout)
+	}
+	clock.now = clock.now.Add(30 * time.Second)
+
+	t.Log("Checking success resets failures...")
+	limiter.Success("user admin")
+	if lockout := limiter.Failure("user admin"); lockout != 0 {
+		t.Errorf("Expected no lockout after success, but received %v", lockout)
+	}
+
+	stats := limiter.Stats()
+	if stats.Failures != 8 || stats.Lockouts != 5 || stats.LockedOut != 5 {
+		t.Errorf("Expected 8 failures, 5 lockouts and 5 locked out requests, but received %+v", stats)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	clock := &testClock{now: time.Unix(1000, 0)}
+	limiter := New(&nozzleconfiguration.RateLimitConfiguration{LockoutSeconds: 10, MaxLockoutSeconds: 60}, clock.Now)
+
+	limiter.Allow("ip 10.0.0.1")
+	limiter.Failure("user admin")
+
+	t.Log("Checking keys are pruned once nothing is left to remember... (expected tracked keys: 1)")
+	clock.now = clock.now.Add(61 * time.Second)
+	limiter.Allow("ip 10.0.0.2")
+
+	if stats := limiter.Stats(); stats.TrackedKeys != 1 {
+		t.Errorf("Expected 1 tracked key, but received %d", stats.TrackedKeys)
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package stats
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+//DefaultOrigin is the origin of the metrics the nozzle exports about itself
+const DefaultOrigin = "bluemedora-firehose-nozzle"
+
+//Names of the counters and gauges of the nozzle, per event type, origin, path and status counters append those
+//to the name
+const (
+	EnvelopesReceived            = "envelopes.received"
+	EnvelopesReceivedByEventType = "envelopes.received.eventType"
+	EnvelopesReceivedByOrigin    = "envelopes.received.origin"
+	EnvelopesPerSecond           = "envelopes.perSecond"
+	EnvelopesIgnored             = "envelopes.ignored"
+	EnvelopesUnknown             = "envelopes.unknown"
+	EnvelopesOwn                 = "envelopes.own"
+	CacheResources               = "cache.resources"
+	CacheSeries                  = "cache.series"
+	Flushes                      = "flush.count"
+	FlushDuration                = "flush.durationMs"
+	APIRequests                  = "api.requests"
+	TokensActive                 = "tokens.active"
+	FirehoseReconnects           = "firehose.reconnects"
+)
+
+var units = map[string]string{
+	EnvelopesPerSecond: "envelopes/s",
+	FlushDuration:      "ms",
+	CacheResources:     "resources",
+	CacheSeries:        "series",
+	TokensActive:       "tokens",
+}
+
+//Registry holds the counters and gauges the nozzle keeps about itself
+type Registry struct {
+	mutex    sync.Mutex
+	counters map[string]uint64
+	gauges   map[string]float64
+}
+
+//Snapshot is a copy of the counters and gauges of a Registry
+type Snapshot struct {
+	Counters map[string]uint64
+	Gauges   map[string]float64
+}
+
+//Source identifies the nozzle instance the exported metrics belong to
+type Source struct {
+	Origin string
+	Job    string
+	Index  string
+	IP     string
+}
+
+//New Registry
+func New() *Registry {
+	return &Registry{
+		counters: make(map[string]uint64),
+		gauges:   make(map[string]float64),
+	}
+}
+
+//Name appends parts to the metric name prefix, dots and slashes within a part are replaced so they do not nest the name
+func Name(prefix string, parts ...string) string {
+	replacer := strings.NewReplacer(".", "_", "/", "_", " ", "_")
+
+	names := []string{prefix}
+	for _, part := range parts {
+		part = replacer.Replace(strings.Trim(part, "/"))
+		if part != "" {
+			names = append(names, part)
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+//Add adds delta to the counter name
+func (registry *Registry) Add(name string, delta uint64) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.counters[name] += delta
+}
+
+//Increment adds one to the counter name
+func (registry *Registry) Increment(name string) {
+	registry.Add(name, 1)
+}
+
+//Counter returns the total of the counter name
+func (registry *Registry) Counter(name string) uint64 {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	return registry.counters[name]
+}
+
+//Set sets the gauge name to value
+func (registry *Registry) Set(name string, value float64) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.gauges[name] = value
+}
+
+//Snapshot returns a copy of every counter and gauge
+func (registry *Registry) Snapshot() Snapshot {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	snapshot := Snapshot{
+		Counters: make(map[string]uint64, len(registry.counters)),
+		Gauges:   make(map[string]float64, len(registry.gauges)),
+	}
+
+	for name, value := range registry.counters {
+		snapshot.Counters[name] = value
+	}
+
+	for name, value := range registry.gauges {
+		snapshot.Gauges[name] = value
+	}
+
+	return snapshot
+}
+
+//Envelopes converts the gauges to value metrics and the counters to counter events, deltas are relative to previous
+func (snapshot Snapshot) Envelopes(source Source, previous Snapshot, timestamp time.Time) []*events.Envelope {
+	envelopes := make([]*events.Envelope, 0, len(snapshot.Counters)+len(snapshot.Gauges))
+
+	gaugeNames := make([]string, 0, len(snapshot.Gauges))
+	for name := range snapshot.Gauges {
+		gaugeNames = append(gaugeNames, name)
+	}
+	sort.Strings(gaugeNames)
+
+	counterNames := make([]string, 0, len(snapshot.Counters))
+	for name := range snapshot.Counters {
+		counterNames = append(counterNames, name)
+	}
+	sort.Strings(counterNames)
+
+	for _, name := range gaugeNames {
+		name, value, unit := name, snapshot.Gauges[name], unitOf(name)
+		envelope := source.envelope(events.Envelope_ValueMetric, timestamp)
+		envelope.ValueMetric = &events.ValueMetric{Name: &name, Value: &value, Unit: &unit}
+		envelopes = append(envelopes, envelope)
+	}
+
+	for _, name := range counterNames {
+		name, total := name, snapshot.Counters[name]
+		//A counter below its previous total was reset, its whole total is new
+		delta := total
+		if previous.Counters[name] <= total {
+			delta = total - previous.Counters[name]
+		}
+
+		envelope := source.envelope(events.Envelope_CounterEvent, timestamp)
+		envelope.CounterEvent = &events.CounterEvent{Name: &name, Delta: &delta, Total: &total}
+		envelopes = append(envelopes, envelope)
+	}
+
+	return envelopes
+}
+
+func (source Source) envelope(eventType events.Envelope_EventType, timestamp time.Time) *events.Envelope {
+	nanoseconds := timestamp.UnixNano()
+	envelope := &events.Envelope{
+		Origin:    &source.Origin,
+		EventType: &eventType,
+		Timestamp: &nanoseconds,
+	}
+
+	if source.Job != "" {
+		envelope.Job = &source.Job
+	}
+
+	if source.Index != "" {
+		envelope.Index = &source.Index
+	}
+
+	if source.IP != "" {
+		envelope.Ip = &source.IP
+	}
+
+	return envelope
+}
+
+func unitOf(name string) string {
+	if unit, ok := units[name]; ok {
+		return unit
+	}
+	return "count"
+}
+
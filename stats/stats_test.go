@@ -0,0 +1,67 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+func TestName(t *testing.T) {
+	name := Name(APIRequests, "/admin/tokens", "200")
+
+	t.Log("Check if path is flattened into the name... (expecting api.requests.admin_tokens.200)")
+	if name != "api.requests.admin_tokens.200" {
+		t.Errorf("Expected api.requests.admin_tokens.200, but received %s", name)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	registry := New()
+	registry.Increment(EnvelopesReceived)
+	registry.Add(EnvelopesReceived, 2)
+	registry.Set(CacheResources, 5)
+
+	snapshot := registry.Snapshot()
+	registry.Increment(EnvelopesReceived)
+
+	t.Log("Check if snapshot is a copy... (expecting 3 envelopes and 5 resources)")
+	if snapshot.Counters[EnvelopesReceived] != 3 || snapshot.Gauges[CacheResources] != 5 {
+		t.Errorf("Expected 3 envelopes and 5 resources, but received %+v", snapshot)
+	}
+
+	t.Log("Check if registry keeps counting... (expecting 4 envelopes)")
+	if registry.Counter(EnvelopesReceived) != 4 {
+		t.Errorf("Expected 4 envelopes, but received %d", registry.Counter(EnvelopesReceived))
+	}
+}
+
+func TestEnvelopes(t *testing.T) {
+	previous := Snapshot{Counters: map[string]uint64{EnvelopesReceived: 10}}
+	snapshot := Snapshot{
+		Counters: map[string]uint64{EnvelopesReceived: 25},
+		Gauges:   map[string]float64{FlushDuration: 12.5},
+	}
+
+	envelopes := snapshot.Envelopes(Source{Origin: DefaultOrigin, Index: "0"}, previous, time.Now())
+	if len(envelopes) != 2 {
+		t.Fatalf("Expected 2 envelopes, but received %d", len(envelopes))
+	}
+
+	valueMetric := envelopes[0]
+	t.Log("Check if gauge is converted to a value metric... (expecting flush.durationMs of 12.5 ms)")
+	if valueMetric.GetEventType() != events.Envelope_ValueMetric || valueMetric.GetOrigin() != DefaultOrigin ||
+		valueMetric.GetValueMetric().GetValue() != 12.5 || valueMetric.GetValueMetric().GetUnit() != "ms" {
+		t.Errorf("Expected value metric flush.durationMs of 12.5 ms, but received %v", valueMetric)
+	}
+
+	counterEvent := envelopes[1]
+	t.Log("Check if counter is converted to a counter event... (expecting delta 15 and total 25)")
+	if counterEvent.GetEventType() != events.Envelope_CounterEvent || counterEvent.GetIndex() != "0" ||
+		counterEvent.GetCounterEvent().GetDelta() != 15 || counterEvent.GetCounterEvent().GetTotal() != 25 {
+		t.Errorf("Expected counter event with delta 15 and total 25, but received %v", counterEvent)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package syslogsink
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+//RFC 5424 constants
+const (
+	facilityUser     = 1
+	severityError    = 3
+	severityInfo     = 6
+	nilValue         = "-"
+	structuredDataID = "firehose@47450"
+	maxAppNameLength = 48
+	maxProcIDLength  = 128
+
+	//TIME-SECFRAC allows at most 6 digits
+	timestampLayout = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var sdValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+type sdParam struct {
+	name, value string
+}
+
+//formatEnvelope renders a LogMessage or Error envelope as an RFC 5424 message without framing
+func formatEnvelope(envelope *events.Envelope, hostname string) []byte {
+	var severity int
+	var timestamp int64
+	appName, procID := nilValue, nilValue
+	var message []byte
+
+	params := []sdParam{
+		{"origin", envelope.GetOrigin()},
+		{"deployment", envelope.GetDeployment()},
+		{"job", envelope.GetJob()},
+		{"index", envelope.GetIndex()},
+		{"ip", envelope.GetIp()},
+	}
+
+	switch envelope.GetEventType() {
+	case events.Envelope_LogMessage:
+		logMessage := envelope.GetLogMessage()
+
+		severity = severityInfo
+		if logMessage.GetMessageType() == events.LogMessage_ERR {
+			severity = severityError
+		}
+
+		timestamp = logMessage.GetTimestamp()
+		appName = headerValue(logMessage.GetAppId(), maxAppNameLength)
+		if logMessage.GetSourceType() != "" {
+			procID = headerValue(fmt.Sprintf("[%s/%s]", logMessage.GetSourceType(), logMessage.GetSourceInstance()), maxProcIDLength)
+		}
+
+		message = logMessage.GetMessage()
+		params = append(params,
+			sdParam{"app_guid", logMessage.GetAppId()},
+			sdParam{"source_type", logMessage.GetSourceType()},
+			sdParam{"source_instance", logMessage.GetSourceInstance()},
+			sdParam{"message_type", logMessage.GetMessageType().String()})
+	case events.Envelope_Error:
+		errorEvent := envelope.GetError()
+
+		severity = severityError
+		timestamp = envelope.GetTimestamp()
+		appName = headerValue(envelope.GetOrigin(), maxAppNameLength)
+		message = []byte(errorEvent.GetMessage())
+		params = append(params,
+			sdParam{"source", errorEvent.GetSource()},
+			sdParam{"code", fmt.Sprintf("%d", errorEvent.GetCode())})
+	}
+
+	if timestamp == 0 {
+		timestamp = time.Now().UnixNano()
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "<%d>1 %s %s %s %s %s ",
+		facilityUser*8+severity,
+		time.Unix(0, timestamp).UTC().Format(timestampLayout),
+		headerValue(hostname, 255),
+		appName,
+		procID,
+		envelope.GetEventType().String())
+	writeStructuredData(&buffer, params)
+	buffer.WriteByte(' ')
+	buffer.Write(bytes.TrimRight(message, "\r\n"))
+
+	return buffer.Bytes()
+}
+
+func writeStructuredData(buffer *bytes.Buffer, params []sdParam) {
+	buffer.WriteString("[" + structuredDataID)
+	for _, param := range params {
+		if param.value != "" {
+			fmt.Fprintf(buffer, ` %s="%s"`, param.name, sdValueEscaper.Replace(param.value))
+		}
+	}
+	buffer.WriteString("]")
+}
+
+//headerValue makes a value safe for a header field, header fields are printable ascii without spaces
+func headerValue(value string, maxLength int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+
+	if cleaned == "" {
+		return nilValue
+	}
+
+	if len(cleaned) > maxLength {
+		cleaned = cleaned[:maxLength]
+	}
+
+	return cleaned
+}
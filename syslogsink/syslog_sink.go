@@ -0,0 +1,295 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package syslogsink
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+//Syslog sink constants
+const (
+	ProtocolTCP = "tcp"
+	ProtocolTLS = "tls"
+	ProtocolUDP = "udp"
+
+	defaultQueueSize  = 1000
+	dialTimeout       = 5 * time.Second
+	writeTimeout      = 5 * time.Second
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+//DeliveryMetrics counts envelopes handled by the sink since it was created
+type DeliveryMetrics struct {
+	Forwarded uint64
+	Failed    uint64
+	Dropped   uint64
+	Filtered  uint64
+}
+
+//SyslogSink forwards LogMessage and Error envelopes to a syslog collector
+type SyslogSink struct {
+	//Counters are kept first to stay 64-bit aligned for atomic access
+	forwarded uint64
+	failed    uint64
+	dropped   uint64
+	filtered  uint64
+
+	config       *nozzleconfiguration.SyslogConfiguration
+	logger       *gosteno.Logger
+	tlsConfig    *tls.Config
+	hostname     string
+	appGUIDs     map[string]bool
+	eventTypes   map[events.Envelope_EventType]bool
+	messageTypes map[events.LogMessage_MessageType]bool
+
+	queue chan []byte
+	done  chan struct{}
+
+	//Only accessed by the writer goroutine
+	conn           net.Conn
+	reconnectDelay time.Duration
+	retryAt        time.Time
+}
+
+//New creates a SyslogSink, the connection to the collector is made when the first message is sent
+func New(config *nozzleconfiguration.SyslogConfiguration, logger *gosteno.Logger) (*SyslogSink, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("No syslog address configured")
+	}
+
+	if config.Protocol == "" {
+		config.Protocol = ProtocolTCP
+	}
+
+	if config.Protocol != ProtocolTCP && config.Protocol != ProtocolTLS && config.Protocol != ProtocolUDP {
+		return nil, fmt.Errorf("Unknown syslog protocol %s", config.Protocol)
+	}
+
+	sink := &SyslogSink{
+		config:         config,
+		logger:         logger,
+		hostname:       config.Hostname,
+		appGUIDs:       make(map[string]bool),
+		eventTypes:     make(map[events.Envelope_EventType]bool),
+		messageTypes:   make(map[events.LogMessage_MessageType]bool),
+		reconnectDelay: minReconnectDelay,
+		done:           make(chan struct{}),
+	}
+
+	if sink.hostname == "" {
+		sink.hostname, _ = os.Hostname()
+	}
+
+	for _, appGUID := range config.AppGUIDs {
+		sink.appGUIDs[appGUID] = true
+	}
+
+	for _, name := range config.EventTypes {
+		value, ok := events.Envelope_EventType_value[name]
+		if !ok || (value != int32(events.Envelope_LogMessage) && value != int32(events.Envelope_Error)) {
+			return nil, fmt.Errorf("Syslog event type %s is not LogMessage or Error", name)
+		}
+		sink.eventTypes[events.Envelope_EventType(value)] = true
+	}
+
+	for _, name := range config.MessageTypes {
+		value, ok := events.LogMessage_MessageType_value[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown syslog message type %s", name)
+		}
+		sink.messageTypes[events.LogMessage_MessageType(value)] = true
+	}
+
+	if config.Protocol == ProtocolTLS {
+		tlsConfig, err := createTLSConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		sink.tlsConfig = tlsConfig
+	}
+
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	sink.queue = make(chan []byte, queueSize)
+
+	go sink.run()
+
+	logger.Infof("Forwarding logs to syslog %s://%s", config.Protocol, config.Address)
+	return sink, nil
+}
+
+func createTLSConfig(config *nozzleconfiguration.SyslogConfiguration) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.TLSInsecureSkipVerify}
+
+	if config.TLSCAFile != "" {
+		caBytes, err := ioutil.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading syslog CA file: %s", err)
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("No certificates found in syslog CA file %s", config.TLSCAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+//SendEnvelope queues matching envelopes, envelopes are dropped instead of blocking when the queue is full
+func (sink *SyslogSink) SendEnvelope(envelope *events.Envelope) {
+	eventType := envelope.GetEventType()
+	if eventType != events.Envelope_LogMessage && eventType != events.Envelope_Error {
+		return
+	}
+
+	if !sink.matches(envelope) {
+		atomic.AddUint64(&sink.filtered, 1)
+		return
+	}
+
+	select {
+	case sink.queue <- formatEnvelope(envelope, sink.hostname):
+	default:
+		atomic.AddUint64(&sink.dropped, 1)
+	}
+}
+
+//SendResources is a no-op as only logs are forwarded to syslog
+func (sink *SyslogSink) SendResources(snapshot map[string][]webserver.Resource) {
+}
+
+//Metrics returns the delivery counts of the sink
+func (sink *SyslogSink) Metrics() DeliveryMetrics {
+	return DeliveryMetrics{
+		Forwarded: atomic.LoadUint64(&sink.forwarded),
+		Failed:    atomic.LoadUint64(&sink.failed),
+		Dropped:   atomic.LoadUint64(&sink.dropped),
+		Filtered:  atomic.LoadUint64(&sink.filtered),
+	}
+}
+
+//Close writes out queued messages and closes the connection to the collector
+func (sink *SyslogSink) Close() error {
+	close(sink.queue)
+	<-sink.done
+
+	metrics := sink.Metrics()
+	sink.logger.Infof("Closed syslog sink after forwarding %d, failing %d and dropping %d messages",
+		metrics.Forwarded, metrics.Failed, metrics.Dropped)
+	return nil
+}
+
+func (sink *SyslogSink) matches(envelope *events.Envelope) bool {
+	if len(sink.eventTypes) > 0 && !sink.eventTypes[envelope.GetEventType()] {
+		return false
+	}
+
+	logMessage := envelope.GetLogMessage()
+
+	//Error envelopes do not belong to an app so never match an app filter
+	if len(sink.appGUIDs) > 0 && (logMessage == nil || !sink.appGUIDs[logMessage.GetAppId()]) {
+		return false
+	}
+
+	if len(sink.messageTypes) > 0 && logMessage != nil && !sink.messageTypes[logMessage.GetMessageType()] {
+		return false
+	}
+
+	return true
+}
+
+func (sink *SyslogSink) run() {
+	defer close(sink.done)
+
+	for message := range sink.queue {
+		sink.write(message)
+	}
+
+	if sink.conn != nil {
+		sink.conn.Close()
+	}
+}
+
+//write makes one attempt on the current connection and one on a fresh connection
+func (sink *SyslogSink) write(message []byte) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if sink.conn == nil && !sink.connect() {
+			break
+		}
+
+		sink.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		_, err := sink.conn.Write(sink.frame(message))
+		if err == nil {
+			atomic.AddUint64(&sink.forwarded, 1)
+			return
+		}
+
+		sink.logger.Warnf("Error writing to syslog %s: %s", sink.config.Address, err.Error())
+		sink.conn.Close()
+		sink.conn = nil
+	}
+
+	atomic.AddUint64(&sink.failed, 1)
+}
+
+//connect backs off exponentially between failed attempts so a down collector is not hammered
+func (sink *SyslogSink) connect() bool {
+	if time.Now().Before(sink.retryAt) {
+		return false
+	}
+
+	conn, err := sink.dial()
+	if err != nil {
+		sink.logger.Errorf("Error connecting to syslog %s, retrying in %v: %s", sink.config.Address, sink.reconnectDelay, err.Error())
+		sink.retryAt = time.Now().Add(sink.reconnectDelay)
+		sink.reconnectDelay *= 2
+		if sink.reconnectDelay > maxReconnectDelay {
+			sink.reconnectDelay = maxReconnectDelay
+		}
+		return false
+	}
+
+	sink.logger.Debugf("Connected to syslog %s", sink.config.Address)
+	sink.conn = conn
+	sink.reconnectDelay = minReconnectDelay
+	return true
+}
+
+func (sink *SyslogSink) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+
+	switch sink.config.Protocol {
+	case ProtocolTLS:
+		return tls.DialWithDialer(dialer, "tcp", sink.config.Address, sink.tlsConfig)
+	case ProtocolUDP:
+		return dialer.Dial("udp", sink.config.Address)
+	default:
+		return dialer.Dial("tcp", sink.config.Address)
+	}
+}
+
+//frame uses octet counting (RFC 6587) for stream transports, UDP sends one message per datagram
+func (sink *SyslogSink) frame(message []byte) []byte {
+	if sink.config.Protocol == ProtocolUDP {
+		return message
+	}
+
+	return append([]byte(fmt.Sprintf("%d ", len(message))), message...)
+}
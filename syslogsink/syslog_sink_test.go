@@ -0,0 +1,270 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package syslogsink
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	sinkLogFile         = "bm_syslog_sink.log"
+	sinkLogName         = "bm_syslog_sink"
+	sinkLogLevel        = "debug"
+
+	testAppGUID  = "3f1c6d7a-0f0a-4a57-9d0b-6f5b8bbd4a3e"
+	testHostname = "nozzle-host"
+	testTime     = int64(1475000000123456789)
+)
+
+func TestFormatLogMessage(t *testing.T) {
+	expected := `<11>1 2016-09-27T18:13:20.123456Z nozzle-host 3f1c6d7a-0f0a-4a57-9d0b-6f5b8bbd4a3e [APP/0] LogMessage ` +
+		`[firehose@47450 origin="dea_logging_agent" deployment="cf" job="cell" index="0" ip="10.0.0.1" app_guid="3f1c6d7a-0f0a-4a57-9d0b-6f5b8bbd4a3e" ` +
+		`source_type="APP" source_instance="0" message_type="ERR"] failed with "quoted] value"`
+
+	message := string(formatEnvelope(createLogEnvelope(testAppGUID, events.LogMessage_ERR, "failed with \"quoted] value\"\n"), testHostname))
+
+	t.Log("Checking RFC 5424 log message format...")
+	if message != expected {
+		t.Errorf("Expected message\n%s\nbut received\n%s", expected, message)
+	}
+}
+
+func TestFormatProcID(t *testing.T) {
+	envelope := createLogEnvelope(testAppGUID, events.LogMessage_OUT, "message")
+	sourceType := "APP PROC\t" + strings.Repeat("x", 200)
+	envelope.LogMessage.SourceType = &sourceType
+
+	fields := strings.SplitN(string(formatEnvelope(envelope, testHostname)), " ", 7)
+	procID := fields[4]
+
+	t.Log("Checking PROCID is a single header field of at most 128 printable characters...")
+	if len(procID) != maxProcIDLength || strings.ContainsAny(procID, " \t") || !strings.HasPrefix(procID, "[APPPROCxxx") {
+		t.Errorf("Expected PROCID of %d printable characters, but received %q", maxProcIDLength, procID)
+	}
+
+	t.Logf("Checking message id follows PROCID... (expected value: %s)", events.Envelope_LogMessage)
+	if fields[5] != events.Envelope_LogMessage.String() {
+		t.Errorf("Expected message id %s, but received %q", events.Envelope_LogMessage, fields[5])
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	expected := `<11>1 2016-09-27T18:13:20.123456Z nozzle-host dea_logging_agent - Error ` +
+		`[firehose@47450 origin="dea_logging_agent" deployment="cf" job="cell" index="0" ip="10.0.0.1" source="router" code="500"] bad gateway`
+
+	message := string(formatEnvelope(createErrorEnvelope(), testHostname))
+
+	t.Log("Checking RFC 5424 error format...")
+	if message != expected {
+		t.Errorf("Expected message\n%s\nbut received\n%s", expected, message)
+	}
+}
+
+func TestTCPForwarding(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating tcp listener: %s", err.Error())
+	}
+	defer listener.Close()
+
+	received := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+
+			size, _ := strconv.Atoi(strings.TrimSpace(length))
+			frame := make([]byte, size)
+			_, err = io.ReadFull(reader, frame)
+			if err != nil {
+				return
+			}
+			received <- string(frame)
+		}
+	}()
+
+	sink := createSink(t, &nozzleconfiguration.SyslogConfiguration{
+		Protocol: ProtocolTCP,
+		Address:  listener.Addr().String(),
+		Hostname: testHostname,
+		AppGUIDs: []string{testAppGUID},
+	})
+
+	t.Log("Sending log messages to sink...")
+	sink.SendEnvelope(createLogEnvelope(testAppGUID, events.LogMessage_OUT, "first"))
+	sink.SendEnvelope(createLogEnvelope("other-app", events.LogMessage_OUT, "filtered"))
+	sink.SendEnvelope(createErrorEnvelope())
+	sink.SendEnvelope(createLogEnvelope(testAppGUID, events.LogMessage_ERR, "second"))
+	sink.Close()
+
+	checkReceived(t, received, "first")
+	checkReceived(t, received, "second")
+	checkMetrics(t, sink, DeliveryMetrics{Forwarded: 2, Filtered: 2})
+}
+
+func TestUDPForwarding(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating udp listener: %s", err.Error())
+	}
+	defer conn.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		buffer := make([]byte, 65536)
+		n, _, err := conn.ReadFrom(buffer)
+		if err == nil {
+			received <- string(buffer[:n])
+		}
+	}()
+
+	sink := createSink(t, &nozzleconfiguration.SyslogConfiguration{
+		Protocol:     ProtocolUDP,
+		Address:      conn.LocalAddr().String(),
+		Hostname:     testHostname,
+		MessageTypes: []string{"ERR"},
+	})
+
+	t.Log("Sending log messages to sink...")
+	sink.SendEnvelope(createLogEnvelope(testAppGUID, events.LogMessage_OUT, "filtered"))
+	sink.SendEnvelope(createLogEnvelope(testAppGUID, events.LogMessage_ERR, "udp message"))
+	sink.Close()
+
+	checkReceived(t, received, "udp message")
+	checkMetrics(t, sink, DeliveryMetrics{Forwarded: 1, Filtered: 1})
+}
+
+func TestFullQueueDrops(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error creating tcp listener: %s", err.Error())
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	sink := createSink(t, &nozzleconfiguration.SyslogConfiguration{
+		Address:   address,
+		QueueSize: 1,
+	})
+
+	t.Log("Sending more messages than the queue holds to an unreachable collector...")
+	for i := 0; i < 100; i++ {
+		sink.SendEnvelope(createLogEnvelope(testAppGUID, events.LogMessage_OUT, "message"))
+	}
+	sink.Close()
+
+	metrics := sink.Metrics()
+	t.Logf("Checking all messages were dropped or failed... (expected value: 100)")
+	if metrics.Dropped+metrics.Failed != 100 {
+		t.Errorf("Expected 100 dropped or failed messages, but received %+v", metrics)
+	}
+}
+
+func checkReceived(t *testing.T, received chan string, expectedMessage string) {
+	t.Logf("Checking collector received message... (expected message ending: %s)", expectedMessage)
+	select {
+	case message := <-received:
+		if !strings.HasSuffix(message, " "+expectedMessage) {
+			t.Errorf("Expected message ending with %s, but received %s", expectedMessage, message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Errorf("Timed out waiting for message %s", expectedMessage)
+	}
+}
+
+func checkMetrics(t *testing.T, sink *SyslogSink, expected DeliveryMetrics) {
+	t.Logf("Checking delivery metrics... (expected value: %+v)", expected)
+	if metrics := sink.Metrics(); metrics != expected {
+		t.Errorf("Expected delivery metrics %+v, but received %+v", expected, metrics)
+	}
+}
+
+func createSink(t *testing.T, config *nozzleconfiguration.SyslogConfiguration) *SyslogSink {
+	sink, err := New(config, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating syslog sink: %s", err.Error())
+	}
+
+	return sink
+}
+
+func createLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, sinkLogFile, sinkLogName, sinkLogLevel)
+}
+
+func createLogEnvelope(appGUID string, messageType events.LogMessage_MessageType, message string) *events.Envelope {
+	eventType := events.Envelope_LogMessage
+	timestamp := testTime
+	sourceType := "APP"
+	sourceInstance := "0"
+
+	envelope := createEnvelope(&eventType)
+	envelope.LogMessage = &events.LogMessage{
+		Message:        []byte(message),
+		MessageType:    &messageType,
+		Timestamp:      &timestamp,
+		AppId:          &appGUID,
+		SourceType:     &sourceType,
+		SourceInstance: &sourceInstance,
+	}
+
+	return envelope
+}
+
+func createErrorEnvelope() *events.Envelope {
+	eventType := events.Envelope_Error
+	source := "router"
+	code := int32(500)
+	message := "bad gateway"
+
+	envelope := createEnvelope(&eventType)
+	envelope.Error = &events.Error{
+		Source:  &source,
+		Code:    &code,
+		Message: &message,
+	}
+
+	return envelope
+}
+
+func createEnvelope(eventType *events.Envelope_EventType) *events.Envelope {
+	origin := "dea_logging_agent"
+	deployment := "cf"
+	job := "cell"
+	index := "0"
+	ip := "10.0.0.1"
+	timestamp := testTime
+
+	return &events.Envelope{
+		Origin:     &origin,
+		EventType:  eventType,
+		Timestamp:  &timestamp,
+		Deployment: &deployment,
+		Job:        &job,
+		Index:      &index,
+		Ip:         &ip,
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package uaaauth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultTokenKeysCacheSeconds = 600
+
+	//Unknown key IDs trigger a fetch for rotated keys, but not more often than this
+	minTokenKeysRefetch = 10 * time.Second
+)
+
+//Claims are the verified claims of a UAA access token
+type Claims struct {
+	Subject   string   `json:"sub"`
+	UserName  string   `json:"user_name"`
+	ClientID  string   `json:"client_id"`
+	Scope     []string `json:"scope"`
+	Audience  audience `json:"aud"`
+	Issuer    string   `json:"iss"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+}
+
+//Name is the user the token was issued to, or the client for client credentials tokens
+func (claims *Claims) Name() string {
+	if claims.UserName != "" {
+		return claims.UserName
+	}
+	return claims.ClientID
+}
+
+//audience is a single string or a list of strings in a JWT
+type audience []string
+
+func (aud *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*aud = audience{single}
+		return nil
+	}
+
+	var list []string
+	err := json.Unmarshal(data, &list)
+	*aud = audience(list)
+	return err
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+type tokenKey struct {
+	KeyID    string `json:"kid"`
+	KeyType  string `json:"kty"`
+	Modulus  string `json:"n"`
+	Exponent string `json:"e"`
+	Value    string `json:"value"`
+}
+
+//TokenVerifier verifies RS256 signed UAA access tokens with the keys published at /token_keys
+type TokenVerifier struct {
+	config  *nozzleconfiguration.APIAuthenticationConfiguration
+	keysURL string
+	client  *http.Client
+	logger  *gosteno.Logger
+	now     func() time.Time
+
+	mutex     sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	fetching  chan struct{}
+}
+
+//NewTokenVerifier creates a TokenVerifier, keys are fetched from the UAA at uaaURL on first use. An Audience or a
+//RequiredScope must be configured, otherwise any token issued by the UAA would be accepted
+func NewTokenVerifier(uaaURL string, insecureSkipVerify bool, config *nozzleconfiguration.APIAuthenticationConfiguration, logger *gosteno.Logger) (*TokenVerifier, error) {
+	if uaaURL == "" {
+		return nil, fmt.Errorf("No UAA URL configured")
+	}
+
+	if config.Audience == "" && config.RequiredScope == "" {
+		return nil, fmt.Errorf("AcceptBearerTokens requires an Audience or a RequiredScope")
+	}
+
+	if config.Issuer == "" {
+		config.Issuer = strings.TrimSuffix(uaaURL, "/") + "/oauth/token"
+	}
+
+	if config.TokenKeysCacheSeconds == 0 {
+		config.TokenKeysCacheSeconds = defaultTokenKeysCacheSeconds
+	}
+
+	logger.Infof("Accepting bearer tokens signed by UAA %s", uaaURL)
+	return &TokenVerifier{
+		config:  config,
+		keysURL: strings.TrimSuffix(uaaURL, "/") + "/token_keys",
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify}},
+		},
+		logger: logger,
+		now:    time.Now,
+		keys:   make(map[string]*rsa.PublicKey),
+	}, nil
+}
+
+//Verify checks signature, issuer, validity period, audience and scope of token
+func (verifier *TokenVerifier) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("Token is not a JWT")
+	}
+
+	var header tokenHeader
+	err := decodeSegment(parts[0], &header)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token header: %s", err)
+	}
+
+	if header.Algorithm != "RS256" {
+		return nil, fmt.Errorf("Unsupported token algorithm %s", header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token signature encoding: %s", err)
+	}
+
+	key, err := verifier.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token signature")
+	}
+
+	var claims Claims
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid token claims: %s", err)
+	}
+
+	if claims.Issuer != verifier.config.Issuer {
+		return nil, fmt.Errorf("Token of %s is issued by %s instead of %s", claims.Name(), claims.Issuer, verifier.config.Issuer)
+	}
+
+	now := verifier.now()
+	if claims.ExpiresAt == 0 || !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, fmt.Errorf("Token of %s has expired", claims.Name())
+	}
+
+	if claims.NotBefore != 0 && now.Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, fmt.Errorf("Token of %s is not valid yet", claims.Name())
+	}
+
+	if verifier.config.Audience != "" && !contains(claims.Audience, verifier.config.Audience) {
+		return nil, fmt.Errorf("Token of %s is not issued for audience %s", claims.Name(), verifier.config.Audience)
+	}
+
+	if verifier.config.RequiredScope != "" && !contains(claims.Scope, verifier.config.RequiredScope) {
+		return nil, fmt.Errorf("Token of %s is missing scope %s", claims.Name(), verifier.config.RequiredScope)
+	}
+
+	return &claims, nil
+}
+
+//key refreshes the cached keys when they are old or keyID is unknown, the old keys stay in use if UAA can't be reached
+func (verifier *TokenVerifier) key(keyID string) (*rsa.PublicKey, error) {
+	verifier.mutex.Lock()
+	defer verifier.mutex.Unlock()
+
+	now := verifier.now()
+	_, known := verifier.keys[keyID]
+	age := now.Sub(verifier.fetchedAt)
+	stale := age > time.Duration(verifier.config.TokenKeysCacheSeconds)*time.Second || (!known && age > minTokenKeysRefetch)
+
+	if fetching := verifier.fetching; fetching != nil && !known {
+		//Another request is fetching the keys already, wait for its result instead of asking UAA again
+		verifier.mutex.Unlock()
+		<-fetching
+		verifier.mutex.Lock()
+	} else if stale && fetching == nil {
+		verifier.refreshKeys(now)
+	}
+
+	key, known := verifier.keys[keyID]
+
+	//Tokens without a key ID are accepted when UAA only has one key
+	if !known && keyID == "" && len(verifier.keys) == 1 {
+		for _, key = range verifier.keys {
+			known = true
+		}
+	}
+
+	if !known {
+		return nil, fmt.Errorf("Unknown token key %s", keyID)
+	}
+
+	return key, nil
+}
+
+//refreshKeys must be called with the mutex held, it is released while UAA is asked so tokens signed with known keys
+//are verified meanwhile
+func (verifier *TokenVerifier) refreshKeys(now time.Time) {
+	fetching := make(chan struct{})
+	verifier.fetching = fetching
+	verifier.fetchedAt = now
+	verifier.mutex.Unlock()
+
+	keys, err := verifier.fetchKeys()
+
+	verifier.mutex.Lock()
+	if err != nil {
+		verifier.logger.Errorf("Error fetching UAA token keys: %s", err.Error())
+	} else {
+		verifier.keys = keys
+	}
+	verifier.fetching = nil
+	close(fetching)
+}
+
+func (verifier *TokenVerifier) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	response, err := verifier.client.Get(verifier.keysURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("UAA returned status code %d", response.StatusCode)
+	}
+
+	var keySet struct {
+		Keys []tokenKey `json:"keys"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&keySet)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(keySet.Keys))
+	for _, tokenKey := range keySet.Keys {
+		key, err := tokenKey.publicKey()
+		if err != nil {
+			verifier.logger.Warnf("Ignoring UAA token key %s: %s", tokenKey.KeyID, err.Error())
+			continue
+		}
+		keys[tokenKey.KeyID] = key
+	}
+
+	verifier.logger.Debugf("Fetched %d UAA token keys", len(keys))
+	return keys, nil
+}
+
+func (key tokenKey) publicKey() (*rsa.PublicKey, error) {
+	if key.KeyType != "" && key.KeyType != "RSA" {
+		return nil, fmt.Errorf("unsupported key type %s", key.KeyType)
+	}
+
+	if key.Modulus != "" && key.Exponent != "" {
+		modulus, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key.Modulus, "="))
+		if err != nil {
+			return nil, err
+		}
+
+		exponent, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(key.Exponent, "="))
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(modulus),
+			E: int(new(big.Int).SetBytes(exponent).Int64()),
+		}, nil
+	}
+
+	block, _ := pem.Decode([]byte(key.Value))
+	if block == nil {
+		return nil, fmt.Errorf("no key material")
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an RSA key")
+	}
+
+	return publicKey, nil
+}
+
+//TokenExpiry reads the expiry of a token issued to the nozzle itself, the signature is not verified. The token may
+//carry the bearer prefix returned by UAA
+func TokenExpiry(token string) (time.Time, error) {
+	fields := strings.Fields(token)
+	if len(fields) == 2 && strings.EqualFold(fields[0], "bearer") {
+		token = fields[1]
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("Token is not a JWT")
+	}
+
+	var claims Claims
+	err := decodeSegment(parts[1], &claims)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid token claims: %s", err)
+	}
+
+	if claims.ExpiresAt == 0 {
+		return time.Time{}, fmt.Errorf("Token has no expiry")
+	}
+
+	return time.Unix(claims.ExpiresAt, 0), nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
+
+func contains(values []string, required string) bool {
+	for _, value := range values {
+		if value == required {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,271 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package uaaauth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+const (
+	testAudience = "bluemedora-nozzle"
+	testIssuer   = "https://uaa.example.com/oauth/token"
+)
+
+//fakeTokenKeys publishes the public keys of its signing keys like the UAA /token_keys endpoint
+type fakeTokenKeys struct {
+	mutex    sync.Mutex
+	keys     map[string]*rsa.PrivateKey
+	requests int
+	delay    time.Duration
+}
+
+func (tokenKeys *fakeTokenKeys) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	time.Sleep(tokenKeys.delay)
+
+	tokenKeys.mutex.Lock()
+	defer tokenKeys.mutex.Unlock()
+	tokenKeys.requests++
+
+	var keySet struct {
+		Keys []tokenKey `json:"keys"`
+	}
+
+	for keyID, key := range tokenKeys.keys {
+		keySet.Keys = append(keySet.Keys, tokenKey{
+			KeyID:    keyID,
+			KeyType:  "RSA",
+			Modulus:  base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			Exponent: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	json.NewEncoder(w).Encode(keySet)
+}
+
+func (tokenKeys *fakeTokenKeys) setKey(keyID string, key *rsa.PrivateKey) {
+	tokenKeys.mutex.Lock()
+	defer tokenKeys.mutex.Unlock()
+	tokenKeys.keys = map[string]*rsa.PrivateKey{keyID: key}
+}
+
+func TestValidBearerToken(t *testing.T) {
+	key := generateKey(t)
+	verifier, tokenKeys, server := createVerifier(t, "key-1", key)
+	defer server.Close()
+
+	claims, err := verifier.Verify(signToken(t, "key-1", key, createClaims(time.Hour)))
+
+	t.Log("Checking valid token is accepted...")
+	if err != nil {
+		t.Fatalf("Expected token to be valid, but received %s", err.Error())
+	}
+
+	t.Logf("Checking name of token... (expected value: %s)", testUsername)
+	if claims.Name() != testUsername {
+		t.Errorf("Expected name %s, but received %s", testUsername, claims.Name())
+	}
+
+	verifier.Verify(signToken(t, "key-1", key, createClaims(time.Hour)))
+
+	t.Log("Checking token keys are cached... (expected value: 1 request)")
+	if tokenKeys.requests != 1 {
+		t.Errorf("Expected 1 request for token keys, but received %d", tokenKeys.requests)
+	}
+}
+
+func TestInvalidBearerTokens(t *testing.T) {
+	key := generateKey(t)
+	verifier, _, server := createVerifier(t, "key-1", key)
+	defer server.Close()
+
+	wrongAudience := createClaims(time.Hour)
+	wrongAudience["aud"] = "other"
+
+	missingScope := createClaims(time.Hour)
+	missingScope["scope"] = []string{"openid"}
+
+	wrongIssuer := createClaims(time.Hour)
+	wrongIssuer["iss"] = "https://other.example.com/oauth/token"
+
+	notYetValid := createClaims(time.Hour)
+	notYetValid["nbf"] = time.Now().Add(time.Minute).Unix()
+
+	tokens := map[string]string{
+		"expired":        signToken(t, "key-1", key, createClaims(-time.Minute)),
+		"wrong audience": signToken(t, "key-1", key, wrongAudience),
+		"missing scope":  signToken(t, "key-1", key, missingScope),
+		"wrong issuer":   signToken(t, "key-1", key, wrongIssuer),
+		"not yet valid":  signToken(t, "key-1", key, notYetValid),
+		"wrong key":      signToken(t, "key-1", generateKey(t), createClaims(time.Hour)),
+		"unsigned":       encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, createClaims(time.Hour)) + ".",
+		"malformed":      "not-a-token",
+	}
+
+	for name, token := range tokens {
+		t.Logf("Checking %s token is rejected... (expecting error)", name)
+		if _, err := verifier.Verify(token); err == nil {
+			t.Errorf("Expected %s token to be rejected, but it was accepted", name)
+		}
+	}
+}
+
+func TestTokenVerifierConfiguration(t *testing.T) {
+	t.Log("Checking verifier without audience and scope is rejected... (expecting error)")
+	if _, err := NewTokenVerifier("https://uaa.example.com", false, &nozzleconfiguration.APIAuthenticationConfiguration{}, createLogger()); err == nil {
+		t.Error("Expected verifier without audience and scope to be rejected, but it was created")
+	}
+
+	config := &nozzleconfiguration.APIAuthenticationConfiguration{RequiredScope: testScope}
+	_, err := NewTokenVerifier("https://uaa.example.com/", false, config, createLogger())
+
+	t.Logf("Checking issuer defaults to the UAA token endpoint... (expected value: %s)", testIssuer)
+	if err != nil || config.Issuer != testIssuer {
+		t.Errorf("Expected issuer %s, but received %s and error %v", testIssuer, config.Issuer, err)
+	}
+}
+
+func TestConcurrentTokenKeyFetch(t *testing.T) {
+	key := generateKey(t)
+	verifier, tokenKeys, server := createVerifier(t, "key-1", key)
+	defer server.Close()
+	tokenKeys.delay = 100 * time.Millisecond
+
+	token := signToken(t, "key-1", key, createClaims(time.Hour))
+	errors := make(chan error, 10)
+	for i := 0; i < cap(errors); i++ {
+		go func() {
+			_, err := verifier.Verify(token)
+			errors <- err
+		}()
+	}
+
+	t.Log("Checking concurrent tokens wait for the same key fetch...")
+	for i := 0; i < cap(errors); i++ {
+		if err := <-errors; err != nil {
+			t.Errorf("Expected token to be valid, but received %s", err.Error())
+		}
+	}
+
+	t.Log("Checking token keys are fetched once... (expected value: 1 request)")
+	if tokenKeys.requests != 1 {
+		t.Errorf("Expected 1 request for token keys, but received %d", tokenKeys.requests)
+	}
+}
+
+func TestTokenExpiry(t *testing.T) {
+	claims := createClaims(time.Hour)
+	token := "bearer " + signToken(t, "key-1", generateKey(t), claims)
+
+	expiry, err := TokenExpiry(token)
+
+	t.Logf("Checking expiry of bearer token... (expected value: %d)", claims["exp"])
+	if err != nil || expiry.Unix() != claims["exp"].(int64) {
+		t.Errorf("Expected expiry %d, but received %d and error %v", claims["exp"], expiry.Unix(), err)
+	}
+
+	t.Log("Checking malformed token is rejected... (expecting error)")
+	if _, err := TokenExpiry("bearer not-a-token"); err == nil {
+		t.Error("Expected malformed token to be rejected, but it was accepted")
+	}
+}
+
+func TestTokenKeyRotation(t *testing.T) {
+	oldKey := generateKey(t)
+	verifier, tokenKeys, server := createVerifier(t, "key-1", oldKey)
+	defer server.Close()
+
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	t.Log("Verifying token signed with the first key...")
+	if _, err := verifier.Verify(signToken(t, "key-1", oldKey, createClaims(time.Hour))); err != nil {
+		t.Fatalf("Expected token to be valid, but received %s", err.Error())
+	}
+
+	newKey := generateKey(t)
+	tokenKeys.setKey("key-2", newKey)
+	newToken := signToken(t, "key-2", newKey, createClaims(time.Hour))
+
+	t.Log("Checking unknown key is not fetched again right away... (expecting error)")
+	if _, err := verifier.Verify(newToken); err == nil {
+		t.Errorf("Expected token to be rejected before keys are refetched")
+	}
+
+	now = now.Add(minTokenKeysRefetch + time.Second)
+
+	t.Log("Checking rotated key is fetched after the refetch interval...")
+	if _, err := verifier.Verify(newToken); err != nil {
+		t.Errorf("Expected token signed with rotated key to be valid, but received %s", err.Error())
+	}
+}
+
+func createVerifier(t *testing.T, keyID string, key *rsa.PrivateKey) (*TokenVerifier, *fakeTokenKeys, *httptest.Server) {
+	tokenKeys := &fakeTokenKeys{keys: map[string]*rsa.PrivateKey{keyID: key}}
+	server := httptest.NewServer(tokenKeys)
+
+	verifier, err := NewTokenVerifier(server.URL, false, &nozzleconfiguration.APIAuthenticationConfiguration{
+		Audience:      testAudience,
+		RequiredScope: testScope,
+		Issuer:        testIssuer,
+	}, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating token verifier: %s", err.Error())
+	}
+
+	return verifier, tokenKeys, server
+}
+
+func createClaims(expiresIn time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"user_name": testUsername,
+		"client_id": "cf",
+		"aud":       []string{"cf", testAudience},
+		"iss":       testIssuer,
+		"scope":     []string{"openid", testScope},
+		"exp":       time.Now().Add(expiresIn).Unix(),
+	}
+}
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+
+	return key
+}
+
+func signToken(t *testing.T, keyID string, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	signingInput := encodeSegment(t, map[string]string{"alg": "RS256", "kid": keyID, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("Error signing token: %s", err.Error())
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeSegment(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatalf("Error encoding token segment: %s", err.Error())
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
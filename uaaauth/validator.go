@@ -0,0 +1,174 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package uaaauth
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+//Supported grant types
+const (
+	GrantTypeClientCredentials = "client_credentials"
+	GrantTypePassword          = "password"
+
+	defaultClientID      = "cf"
+	defaultCacheSeconds  = 60
+	defaultRequiredScope = "doppler.firehose"
+	requestTimeout       = 10 * time.Second
+)
+
+//Validator checks credentials by requesting a token from UAA
+type Validator struct {
+	config   *nozzleconfiguration.APIAuthenticationConfiguration
+	tokenURL string
+	client   *http.Client
+	logger   *gosteno.Logger
+
+	cacheDuration time.Duration
+	mutex         sync.Mutex
+	cache         map[string]time.Time //Maps hashed credentials to the expiry of their validation
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	Scope       string `json:"scope"`
+}
+
+//New creates a Validator using the token endpoint of the UAA at uaaURL
+func New(uaaURL string, insecureSkipVerify bool, config *nozzleconfiguration.APIAuthenticationConfiguration, logger *gosteno.Logger) (*Validator, error) {
+	if uaaURL == "" {
+		return nil, fmt.Errorf("No UAA URL configured")
+	}
+
+	if config.GrantType == "" {
+		config.GrantType = GrantTypeClientCredentials
+	}
+
+	if config.GrantType != GrantTypeClientCredentials && config.GrantType != GrantTypePassword {
+		return nil, fmt.Errorf("Unknown grant type %s", config.GrantType)
+	}
+
+	if config.ClientID == "" {
+		config.ClientID = defaultClientID
+	}
+
+	if config.CacheSeconds == 0 {
+		config.CacheSeconds = defaultCacheSeconds
+	}
+
+	//Without a scope any UAA user or client could log in
+	if config.RequiredScope == "" {
+		config.RequiredScope = defaultRequiredScope
+	}
+
+	logger.Infof("Validating /token logins with UAA %s using the %s grant", uaaURL, config.GrantType)
+	return &Validator{
+		config:   config,
+		tokenURL: strings.TrimSuffix(uaaURL, "/") + "/oauth/token",
+		client: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify}},
+		},
+		logger:        logger,
+		cacheDuration: time.Duration(config.CacheSeconds) * time.Second,
+		cache:         make(map[string]time.Time),
+	}, nil
+}
+
+//Validate returns an error only when UAA could not be asked, rejected credentials return false
+func (validator *Validator) Validate(username string, password string) (bool, error) {
+	key := HashCredentials(username, password)
+	now := time.Now()
+
+	validator.mutex.Lock()
+	expiry, cached := validator.cache[key]
+	validator.mutex.Unlock()
+
+	if cached && now.Before(expiry) {
+		validator.logger.Debugf("Using cached validation of %s", username)
+		return true, nil
+	}
+
+	valid, err := validator.requestToken(username, password)
+	if err != nil || !valid {
+		return valid, err
+	}
+
+	validator.mutex.Lock()
+	for cachedKey, cachedExpiry := range validator.cache {
+		if !now.Before(cachedExpiry) {
+			delete(validator.cache, cachedKey)
+		}
+	}
+	validator.cache[key] = now.Add(validator.cacheDuration)
+	validator.mutex.Unlock()
+
+	return true, nil
+}
+
+func (validator *Validator) requestToken(username string, password string) (bool, error) {
+	data := url.Values{"grant_type": {validator.config.GrantType}}
+
+	clientID, clientSecret := username, password
+	if validator.config.GrantType == GrantTypePassword {
+		data.Set("username", username)
+		data.Set("password", password)
+		clientID, clientSecret = validator.config.ClientID, validator.config.ClientSecret
+	} else {
+		data.Set("client_id", username)
+	}
+
+	request, err := http.NewRequest("POST", validator.tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return false, err
+	}
+	request.SetBasicAuth(clientID, clientSecret)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := validator.client.Do(request)
+	if err != nil {
+		return false, fmt.Errorf("Error requesting token from UAA: %s", err)
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusBadRequest || response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		validator.logger.Debugf("UAA rejected credentials of %s with status code %d", username, response.StatusCode)
+		return false, nil
+	case response.StatusCode != http.StatusOK:
+		return false, fmt.Errorf("UAA returned status code %d", response.StatusCode)
+	}
+
+	var token tokenResponse
+	err = json.NewDecoder(response.Body).Decode(&token)
+	if err != nil {
+		return false, fmt.Errorf("Error decoding UAA token response: %s", err)
+	}
+
+	if !contains(strings.Fields(token.Scope), validator.config.RequiredScope) {
+		validator.logger.Warnf("User %s is missing scope %s", username, validator.config.RequiredScope)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+//HashCredentials keeps plain passwords out of caches of validated credentials
+func HashCredentials(username string, password string) string {
+	sum := sha256.Sum256([]byte(username + "\x00" + password))
+	return hex.EncodeToString(sum[:])
+}
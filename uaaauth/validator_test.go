@@ -0,0 +1,161 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package uaaauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	validatorLogFile    = "bm_uaaauth.log"
+	validatorLogName    = "bm_uaaauth"
+	validatorLogLevel   = "debug"
+
+	testUsername = "metrics-user"
+	testPassword = "metrics-password"
+	testScope    = "firehose.metrics"
+)
+
+//fakeUAA grants tokens with its scopes to the test user, or to the test client for the client credentials grant
+type fakeUAA struct {
+	mutex    sync.Mutex
+	requests int
+	scopes   string
+	broken   bool
+}
+
+func (uaa *fakeUAA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uaa.mutex.Lock()
+	uaa.requests++
+	uaa.mutex.Unlock()
+
+	if uaa.broken {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	clientID, clientSecret, _ := r.BasicAuth()
+	r.ParseForm()
+
+	var valid bool
+	switch r.PostForm.Get("grant_type") {
+	case GrantTypePassword:
+		valid = clientID == "cf" && clientSecret == "" &&
+			r.PostForm.Get("username") == testUsername && r.PostForm.Get("password") == testPassword
+	case GrantTypeClientCredentials:
+		valid = clientID == testUsername && clientSecret == testPassword
+	}
+
+	if !valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokenResponse{AccessToken: "access-token", Scope: uaa.scopes})
+}
+
+func TestPasswordGrant(t *testing.T) {
+	uaa := &fakeUAA{scopes: "openid " + testScope}
+	server := httptest.NewServer(uaa)
+	defer server.Close()
+
+	validator := createValidator(t, server.URL, &nozzleconfiguration.APIAuthenticationConfiguration{
+		GrantType:     GrantTypePassword,
+		RequiredScope: testScope,
+	})
+
+	checkValidation(t, validator, testUsername, testPassword, true)
+	checkValidation(t, validator, testUsername, "wrong", false)
+
+	t.Log("Validating the same credentials again...")
+	checkValidation(t, validator, testUsername, testPassword, true)
+
+	t.Log("Checking successful validation was cached... (expected value: 2 requests)")
+	if uaa.requests != 2 {
+		t.Errorf("Expected 2 requests to UAA, but received %d", uaa.requests)
+	}
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	server := httptest.NewServer(&fakeUAA{scopes: defaultRequiredScope})
+	defer server.Close()
+
+	validator := createValidator(t, server.URL, &nozzleconfiguration.APIAuthenticationConfiguration{})
+
+	checkValidation(t, validator, testUsername, testPassword, true)
+	checkValidation(t, validator, "other-client", testPassword, false)
+}
+
+func TestMissingScope(t *testing.T) {
+	server := httptest.NewServer(&fakeUAA{scopes: "openid"})
+	defer server.Close()
+
+	validator := createValidator(t, server.URL, &nozzleconfiguration.APIAuthenticationConfiguration{
+		RequiredScope: testScope,
+	})
+
+	checkValidation(t, validator, testUsername, testPassword, false)
+}
+
+func TestDefaultRequiredScope(t *testing.T) {
+	server := httptest.NewServer(&fakeUAA{scopes: "openid " + testScope})
+	defer server.Close()
+
+	config := &nozzleconfiguration.APIAuthenticationConfiguration{}
+	validator := createValidator(t, server.URL, config)
+
+	t.Logf("Checking required scope defaults... (expected value: %s)", defaultRequiredScope)
+	if config.RequiredScope != defaultRequiredScope {
+		t.Errorf("Expected required scope %s, but received %s", defaultRequiredScope, config.RequiredScope)
+	}
+
+	checkValidation(t, validator, testUsername, testPassword, false)
+}
+
+func TestUAAUnavailable(t *testing.T) {
+	server := httptest.NewServer(&fakeUAA{broken: true})
+	defer server.Close()
+
+	validator := createValidator(t, server.URL, &nozzleconfiguration.APIAuthenticationConfiguration{})
+
+	t.Log("Validating credentials while UAA fails... (expecting error)")
+	if _, err := validator.Validate(testUsername, testPassword); err == nil {
+		t.Errorf("Expected error validating credentials, but received none")
+	}
+}
+
+func checkValidation(t *testing.T, validator *Validator, username string, password string, expected bool) {
+	t.Logf("Validating credentials of %s... (expected value: %v)", username, expected)
+	valid, err := validator.Validate(username, password)
+	if err != nil {
+		t.Fatalf("Error validating credentials: %s", err.Error())
+	}
+
+	if valid != expected {
+		t.Errorf("Expected validation %v, but received %v", expected, valid)
+	}
+}
+
+func createValidator(t *testing.T, uaaURL string, config *nozzleconfiguration.APIAuthenticationConfiguration) *Validator {
+	validator, err := New(uaaURL, false, config, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating validator: %s", err.Error())
+	}
+
+	return validator
+}
+
+func createLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, validatorLogFile, validatorLogName, validatorLogLevel)
+}
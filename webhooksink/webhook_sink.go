@@ -0,0 +1,334 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webhooksink
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webserver"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+//Webhook sink constants
+const (
+	MetricTypeGauge   = "gauge"
+	MetricTypeCounter = "counter"
+
+	defaultTemplate     = "{{json .Metrics}}"
+	defaultMethod       = "POST"
+	defaultBatchSize    = 100
+	defaultTimeout      = 10
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 500
+	maxQueuedBatches    = 10
+
+	//Retry-After of a server is honored up to this long
+	maxRetryAfter = time.Minute
+)
+
+//Metric is a single value or counter metric made available to the body template
+type Metric struct {
+	Origin     string
+	Deployment string
+	Job        string
+	Index      string
+	IP         string
+	Name       string
+	Value      float64
+	Unit       string
+	Type       string
+	Timestamp  time.Time
+}
+
+//TemplateData is passed to the body template for every batch
+type TemplateData struct {
+	Metrics []Metric
+}
+
+//DeliveryMetrics counts metrics handled by the sink since it was created
+type DeliveryMetrics struct {
+	Delivered uint64
+	Failed    uint64
+	Dropped   uint64
+}
+
+//WebhookSink posts batches of metrics to a URL using a configurable body template
+type WebhookSink struct {
+	//Counters are kept first to stay 64-bit aligned for atomic access
+	delivered uint64
+	failed    uint64
+	dropped   uint64
+
+	config   *nozzleconfiguration.WebhookConfiguration
+	logger   *gosteno.Logger
+	template *template.Template
+	client   *http.Client
+
+	mutex   sync.Mutex
+	pending []Metric
+	batches chan []Metric
+	closing chan struct{}
+	done    chan struct{}
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		valueBytes, err := json.Marshal(value)
+		return string(valueBytes), err
+	},
+}
+
+//New creates a WebhookSink posting to the configured URL
+func New(config *nozzleconfiguration.WebhookConfiguration, logger *gosteno.Logger) (*WebhookSink, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("No webhook URL configured")
+	}
+
+	if config.Method == "" {
+		config.Method = defaultMethod
+	}
+
+	if config.Template == "" {
+		config.Template = defaultTemplate
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultBatchSize
+	}
+
+	if config.TimeoutSeconds == 0 {
+		config.TimeoutSeconds = defaultTimeout
+	}
+
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = defaultMaxRetries
+	}
+
+	if config.RetryBackoffMilliseconds == 0 {
+		config.RetryBackoffMilliseconds = defaultRetryBackoff
+	}
+
+	bodyTemplate, err := template.New("webhook").Funcs(templateFuncs).Parse(config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing webhook template: %s", err)
+	}
+
+	sink := &WebhookSink{
+		config:   config,
+		logger:   logger,
+		template: bodyTemplate,
+		client: &http.Client{
+			Timeout:   time.Duration(config.TimeoutSeconds) * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: config.InsecureSSLSkipVerify}},
+		},
+		batches: make(chan []Metric, maxQueuedBatches),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go sink.run()
+
+	logger.Infof("Posting metrics to webhook %s", config.URL)
+	return sink, nil
+}
+
+//SendEnvelope adds value metrics and counter events to the current batch
+func (sink *WebhookSink) SendEnvelope(envelope *events.Envelope) {
+	metric := Metric{
+		Origin:     envelope.GetOrigin(),
+		Deployment: envelope.GetDeployment(),
+		Job:        envelope.GetJob(),
+		Index:      envelope.GetIndex(),
+		IP:         envelope.GetIp(),
+		Timestamp:  time.Unix(0, envelope.GetTimestamp()),
+	}
+
+	switch envelope.GetEventType() {
+	case events.Envelope_ValueMetric:
+		valueMetric := envelope.GetValueMetric()
+		metric.Name = valueMetric.GetName()
+		metric.Value = valueMetric.GetValue()
+		metric.Unit = valueMetric.GetUnit()
+		metric.Type = MetricTypeGauge
+	case events.Envelope_CounterEvent:
+		counterEvent := envelope.GetCounterEvent()
+		metric.Name = counterEvent.GetName()
+		metric.Value = float64(counterEvent.GetTotal())
+		metric.Type = MetricTypeCounter
+	default:
+		return
+	}
+
+	sink.mutex.Lock()
+	sink.pending = append(sink.pending, metric)
+	if len(sink.pending) >= sink.config.BatchSize {
+		sink.queueBatch()
+	}
+	sink.mutex.Unlock()
+}
+
+//SendResources sends the partial batch on every cache flush so metrics are not held back for long
+func (sink *WebhookSink) SendResources(snapshot map[string][]webserver.Resource) {
+	sink.mutex.Lock()
+	sink.queueBatch()
+	sink.mutex.Unlock()
+}
+
+//Metrics returns the delivery counts of the sink
+func (sink *WebhookSink) Metrics() DeliveryMetrics {
+	return DeliveryMetrics{
+		Delivered: atomic.LoadUint64(&sink.delivered),
+		Failed:    atomic.LoadUint64(&sink.failed),
+		Dropped:   atomic.LoadUint64(&sink.dropped),
+	}
+}
+
+//Close posts the pending batch and waits for queued batches to be sent, each gets one attempt without retries
+func (sink *WebhookSink) Close() error {
+	sink.mutex.Lock()
+	sink.queueBatch()
+	close(sink.batches)
+	close(sink.closing)
+	sink.mutex.Unlock()
+
+	<-sink.done
+
+	metrics := sink.Metrics()
+	sink.logger.Infof("Closed webhook sink after delivering %d, failing %d and dropping %d metrics",
+		metrics.Delivered, metrics.Failed, metrics.Dropped)
+	return nil
+}
+
+//queueBatch must be called with the mutex held, batches are dropped when the sender falls behind
+func (sink *WebhookSink) queueBatch() {
+	if len(sink.pending) == 0 {
+		return
+	}
+
+	select {
+	case sink.batches <- sink.pending:
+	default:
+		atomic.AddUint64(&sink.dropped, uint64(len(sink.pending)))
+		sink.logger.Warnf("Dropped batch of %d metrics as webhook %s is falling behind", len(sink.pending), sink.config.URL)
+	}
+
+	sink.pending = nil
+}
+
+func (sink *WebhookSink) run() {
+	defer close(sink.done)
+
+	for batch := range sink.batches {
+		err := sink.post(batch)
+		if err != nil {
+			sink.logger.Errorf("Error posting %d metrics to webhook %s: %s", len(batch), sink.config.URL, err.Error())
+			atomic.AddUint64(&sink.failed, uint64(len(batch)))
+		} else {
+			atomic.AddUint64(&sink.delivered, uint64(len(batch)))
+		}
+	}
+}
+
+//post retries with exponential backoff on connection errors, 429 and 5xx responses, waiting as long as Retry-After
+//asks for instead when the server sends it. Retries stop once the sink is closing
+func (sink *WebhookSink) post(batch []Metric) error {
+	var body bytes.Buffer
+	err := sink.template.Execute(&body, TemplateData{Metrics: batch})
+	if err != nil {
+		return fmt.Errorf("Error executing template: %s", err)
+	}
+
+	backoff := time.Duration(sink.config.RetryBackoffMilliseconds) * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		retry, retryAfter, err := sink.send(body.Bytes())
+		if err == nil || !retry || attempt >= sink.config.MaxRetries {
+			return err
+		}
+
+		wait := backoff
+		if retryAfter > 0 {
+			wait = retryAfter
+		}
+
+		sink.logger.Debugf("Retrying webhook %s in %v: %s", sink.config.URL, wait, err.Error())
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-sink.closing:
+			timer.Stop()
+			return fmt.Errorf("%s, not retried as the sink is closing", err)
+		}
+		backoff *= 2
+	}
+}
+
+//send returns whether a failed request should be retried and how long the server asked to wait
+func (sink *WebhookSink) send(body []byte) (bool, time.Duration, error) {
+	request, err := http.NewRequest(sink.config.Method, sink.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+
+	for name, value := range sink.config.Headers {
+		request.Header.Set(name, value)
+	}
+
+	if sink.config.BearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+sink.config.BearerToken)
+	} else if sink.config.BasicAuthUsername != "" {
+		request.SetBasicAuth(sink.config.BasicAuthUsername, sink.config.BasicAuthPassword)
+	}
+
+	response, err := sink.client.Do(request)
+	if err != nil {
+		return true, 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, 0, nil
+	}
+
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, parseRetryAfter(response.Header.Get("Retry-After"), time.Now()), fmt.Errorf("Received status code %d", response.StatusCode)
+}
+
+//parseRetryAfter accepts delay seconds or an HTTP date, it returns 0 when the header is missing or invalid
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	var retryAfter time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		retryAfter = date.Sub(now)
+	}
+
+	if retryAfter < 0 {
+		return 0
+	}
+
+	if retryAfter > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return retryAfter
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webhooksink
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+	defaultLogDirectory = "../logs"
+	sinkLogFile         = "bm_webhook_sink.log"
+	sinkLogName         = "bm_webhook_sink"
+	sinkLogLevel        = "debug"
+
+	testTemplate = `{{range $i, $m := .Metrics}}{{if $i}},{{end}}{{$m.Job}}/{{$m.Index}} {{$m.Name}}={{$m.Value}} {{$m.Type}}{{end}}`
+)
+
+type recordedRequest struct {
+	header http.Header
+	body   string
+}
+
+type fakeEndpoint struct {
+	mutex      sync.Mutex
+	requests   []recordedRequest
+	times      []time.Time
+	failures   int
+	retryAfter string
+}
+
+func (endpoint *fakeEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+
+	endpoint.requests = append(endpoint.requests, recordedRequest{r.Header, string(body)})
+	endpoint.times = append(endpoint.times, time.Now())
+	if endpoint.failures > 0 {
+		endpoint.failures--
+		if endpoint.retryAfter != "" {
+			w.Header().Set("Retry-After", endpoint.retryAfter)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func (endpoint *fakeEndpoint) requestCount() int {
+	endpoint.mutex.Lock()
+	defer endpoint.mutex.Unlock()
+
+	return len(endpoint.requests)
+}
+
+func TestTemplatedBatches(t *testing.T) {
+	endpoint := &fakeEndpoint{}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	sink := createSink(t, &nozzleconfiguration.WebhookConfiguration{
+		URL:         server.URL,
+		Template:    testTemplate,
+		Headers:     map[string]string{"Content-Type": "text/plain", "X-Source": "nozzle"},
+		BearerToken: "secret",
+		BatchSize:   2,
+	})
+
+	t.Log("Sending three metrics with a batch size of two...")
+	sink.SendEnvelope(createValueMetric("latency", 12.5))
+	sink.SendEnvelope(createCounterEvent("requests", 40))
+	sink.SendEnvelope(createValueMetric("memory", 1024))
+	sink.Close()
+
+	t.Log("Checking two batches were posted... (expected value: 2)")
+	if len(endpoint.requests) != 2 {
+		t.Fatalf("Expected 2 requests, but received %d", len(endpoint.requests))
+	}
+
+	expectedBodies := []string{"router/0 latency=12.5 gauge,router/0 requests=40 counter", "router/0 memory=1024 gauge"}
+	for i, expected := range expectedBodies {
+		t.Logf("Checking rendered body of batch %d... (expected value: %s)", i, expected)
+		if endpoint.requests[i].body != expected {
+			t.Errorf("Expected body %s, but received %s", expected, endpoint.requests[i].body)
+		}
+	}
+
+	t.Log("Checking configured headers... (expected value: Bearer secret)")
+	header := endpoint.requests[0].header
+	if header.Get("Authorization") != "Bearer secret" || header.Get("X-Source") != "nozzle" {
+		t.Errorf("Expected authorization and custom headers, but received %v", header)
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Delivered: 3})
+}
+
+func TestRetry(t *testing.T) {
+	endpoint := &fakeEndpoint{failures: 2}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	sink := createSink(t, &nozzleconfiguration.WebhookConfiguration{
+		URL:                      server.URL,
+		BasicAuthUsername:        "user",
+		BasicAuthPassword:        "password",
+		RetryBackoffMilliseconds: 1,
+	})
+
+	t.Log("Sending metric to endpoint that fails twice...")
+	sink.SendEnvelope(createValueMetric("latency", 12.5))
+	sink.SendResources(nil)
+	waitForDelivery(t, sink, 1)
+	sink.Close()
+
+	t.Log("Checking request was retried... (expected value: 3)")
+	if len(endpoint.requests) != 3 {
+		t.Fatalf("Expected 3 requests, but received %d", len(endpoint.requests))
+	}
+
+	username, password, ok := (&http.Request{Header: endpoint.requests[2].header}).BasicAuth()
+	t.Log("Checking basic auth header... (expected value: user)")
+	if !ok || username != "user" || password != "password" {
+		t.Errorf("Expected basic auth for user, but received %v", endpoint.requests[2].header)
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Delivered: 1})
+}
+
+func TestGiveUp(t *testing.T) {
+	endpoint := &fakeEndpoint{failures: 10}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	sink := createSink(t, &nozzleconfiguration.WebhookConfiguration{
+		URL:                      server.URL,
+		MaxRetries:               1,
+		RetryBackoffMilliseconds: 1,
+	})
+
+	t.Log("Sending metric to failing endpoint...")
+	sink.SendEnvelope(createValueMetric("latency", 12.5))
+	sink.SendResources(nil)
+	waitForDelivery(t, sink, 1)
+	sink.Close()
+
+	t.Log("Checking retries stop at the configured maximum... (expected value: 2)")
+	if len(endpoint.requests) != 2 {
+		t.Errorf("Expected 2 requests, but received %d", len(endpoint.requests))
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Failed: 1})
+}
+
+func TestRetryAfter(t *testing.T) {
+	endpoint := &fakeEndpoint{failures: 1, retryAfter: "1"}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	sink := createSink(t, &nozzleconfiguration.WebhookConfiguration{
+		URL:                      server.URL,
+		RetryBackoffMilliseconds: 1,
+	})
+
+	t.Log("Sending metric to endpoint that asks to retry after 1 second...")
+	sink.SendEnvelope(createValueMetric("latency", 12.5))
+	sink.SendResources(nil)
+	waitForDelivery(t, sink, 1)
+	sink.Close()
+
+	t.Log("Checking retry waited for Retry-After instead of the backoff... (expected value: at least 1s)")
+	if len(endpoint.times) != 2 || endpoint.times[1].Sub(endpoint.times[0]) < time.Second {
+		t.Errorf("Expected 2 requests 1s apart, but received %v", endpoint.times)
+	}
+}
+
+func TestCloseStopsRetries(t *testing.T) {
+	endpoint := &fakeEndpoint{failures: 10, retryAfter: "60"}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	sink := createSink(t, &nozzleconfiguration.WebhookConfiguration{URL: server.URL})
+
+	sink.SendEnvelope(createValueMetric("latency", 12.5))
+	sink.SendResources(nil)
+	for deadline := time.Now().Add(5 * time.Second); endpoint.requestCount() == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	start := time.Now()
+	sink.Close()
+
+	t.Log("Checking close interrupts the wait for a retry... (expected value: less than 5s)")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected close within 5s, but it took %v", elapsed)
+	}
+
+	checkMetrics(t, sink, DeliveryMetrics{Failed: 1})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2016, 9, 27, 18, 13, 20, 0, time.UTC)
+	values := map[string]time.Duration{
+		"":                              0,
+		"invalid":                       0,
+		"30":                            30 * time.Second,
+		"3600":                          maxRetryAfter,
+		"Tue, 27 Sep 2016 18:13:30 GMT": 10 * time.Second,
+		"Tue, 27 Sep 2016 18:13:00 GMT": 0,
+	}
+
+	for value, expected := range values {
+		t.Logf("Checking Retry-After %q... (expected value: %v)", value, expected)
+		if retryAfter := parseRetryAfter(value, now); retryAfter != expected {
+			t.Errorf("Expected %v, but received %v", expected, retryAfter)
+		}
+	}
+}
+
+func TestBadTemplate(t *testing.T) {
+	t.Log("Checking invalid template... (expecting error)")
+	_, err := New(&nozzleconfiguration.WebhookConfiguration{URL: "http://localhost", Template: "{{.Metrics"}, createLogger())
+	if err == nil {
+		t.Errorf("Expected error creating sink with invalid template, but sink was created")
+	}
+}
+
+func checkMetrics(t *testing.T, sink *WebhookSink, expected DeliveryMetrics) {
+	t.Logf("Checking delivery metrics... (expected value: %+v)", expected)
+	if metrics := sink.Metrics(); metrics != expected {
+		t.Errorf("Expected delivery metrics %+v, but received %+v", expected, metrics)
+	}
+}
+
+//waitForDelivery waits until the sink delivered or failed batches of count metrics, Close would stop its retries
+func waitForDelivery(t *testing.T, sink *WebhookSink, count uint64) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		metrics := sink.Metrics()
+		if metrics.Delivered+metrics.Failed >= count {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d metrics to be sent, but received %+v", count, metrics)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func createSink(t *testing.T, config *nozzleconfiguration.WebhookConfiguration) *WebhookSink {
+	sink, err := New(config, createLogger())
+	if err != nil {
+		t.Fatalf("Error creating webhook sink: %s", err.Error())
+	}
+
+	return sink
+}
+
+func createLogger() *gosteno.Logger {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return logger.New(defaultLogDirectory, sinkLogFile, sinkLogName, sinkLogLevel)
+}
+
+func createValueMetric(name string, value float64) *events.Envelope {
+	envelope := createEnvelope(events.Envelope_ValueMetric)
+	unit := "unit"
+	envelope.ValueMetric = &events.ValueMetric{
+		Name:  &name,
+		Value: &value,
+		Unit:  &unit,
+	}
+
+	return envelope
+}
+
+func createCounterEvent(name string, total uint64) *events.Envelope {
+	envelope := createEnvelope(events.Envelope_CounterEvent)
+	delta := uint64(1)
+	envelope.CounterEvent = &events.CounterEvent{
+		Name:  &name,
+		Delta: &delta,
+		Total: &total,
+	}
+
+	return envelope
+}
+
+func createEnvelope(eventType events.Envelope_EventType) *events.Envelope {
+	origin := "gorouter"
+	deployment := "cf"
+	job := "router"
+	index := "0"
+	ip := "10.0.0.1"
+
+	return &events.Envelope{
+		Origin:     &origin,
+		EventType:  &eventType,
+		Deployment: &deployment,
+		Job:        &job,
+		Index:      &index,
+		Ip:         &ip,
+	}
+}
@@ -4,13 +4,22 @@
 package webserver
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
 	"sync"
+	"time"
 	"encoding/json"
 
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/alerting"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/audit"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/certloader"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/ratelimit"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/uaaauth"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
 	"github.com/cloudfoundry/gosteno"
 	"github.com/cloudfoundry/sonde-go/events"
@@ -23,113 +32,342 @@ const (
 	headerUsernameKey   	= "username"
 	headerPasswordKey   	= "password"
 	headerTokenKey      	= "token"
+	
+	defaultTokenIdleTimeoutSeconds		= 60
+	defaultTokenAbsoluteTimeoutSeconds	= 3600
+	defaultCertificateReloadSeconds		= 10
+	defaultSelfSignedHost				= "localhost"
+	defaultSelfSignedValidDays			= 365
 )
 
-//WebServer REST endpoint for sending data
+//WebServer REST endpoint for sending data, it is also an http.Handler serving every route so it can be mounted
+//into other servers when the nozzle is used as a library
 type WebServer struct {
 	logger *gosteno.Logger
 	mutext sync.Mutex
 	config *nozzleconfiguration.NozzleConfiguration
-	tokens map[string]*webtoken.Token //Maps token string to token object
+	tokens *webtoken.Store
+	
+	sessionMutex  sync.Mutex
+	basicSessions map[string]string //Maps hashed basic authorization credentials to the ID of their session token
 	
 	cache  map[string]map[string]Resource
+	alerts *alerting.Engine
+	evaluation sync.WaitGroup
+	
+	counterRates map[string]*counterRate
+	apiClients   map[string]*apiClient
+	limiter      *ratelimit.Limiter
+	tlsConfig    *tls.Config
+	validator    *uaaauth.Validator
+	tokenVerifier *uaaauth.TokenVerifier
+	auditLogger   *audit.Logger
+	certificates  *certloader.Loader
+	routes        []route
+	router        *http.ServeMux
+	listeners     []*listener
+	
+	serverMutex   sync.Mutex
+	servers       []*http.Server
+	done          chan struct{}
+	shutdownOnce  sync.Once
+	healthMutex   sync.Mutex
+	health        health
+	lastEnvelope  time.Time
+	selfStats     *stats.Registry
+	rateStart     time.Time
+	rateReceived  uint64
 }
 
 //New creates a new WebServer
 func New(config *nozzleconfiguration.NozzleConfiguration, logger *gosteno.Logger) *WebServer {
+	if config.TokenIdleTimeoutSeconds == 0 {
+		config.TokenIdleTimeoutSeconds = defaultTokenIdleTimeoutSeconds
+	}
+	
+	if config.TokenAbsoluteTimeoutSeconds == 0 {
+		config.TokenAbsoluteTimeoutSeconds = defaultTokenAbsoluteTimeoutSeconds
+	}
+	
+	if config.CertificateReloadSeconds == 0 {
+		config.CertificateReloadSeconds = defaultCertificateReloadSeconds
+	}
+	
+	if config.ReadinessWindowSeconds == 0 {
+		config.ReadinessWindowSeconds = defaultReadinessWindowSeconds
+	}
+	
+	setHTTPServerDefaults(&config.HTTPServer)
+	
 	webserver := WebServer{
 		logger: logger,
 		config: config,
-		tokens: make(map[string]*webtoken.Token),
 		cache: 	make(map[string]map[string]Resource),
+		counterRates: make(map[string]*counterRate),
+		basicSessions: make(map[string]string),
+		router: http.NewServeMux(),
+		done: make(chan struct{}),
+		health: health{startedAt: time.Now(), firehoseState: FirehoseDisconnected},
+		selfStats: stats.New(),
+		rateStart: time.Now(),
 	}
 
+	webserver.tokens = webtoken.NewStore(
+		time.Duration(config.TokenIdleTimeoutSeconds) * time.Second,
+		time.Duration(config.TokenAbsoluteTimeoutSeconds) * time.Second,
+		nil, webserver.TokenTimeout)
+
 	webserver.logger.Info("Registering handlers")
 	//setup http handlers
-	http.HandleFunc("/token", webserver.tokenHandler)
-	http.HandleFunc("/metron_agents", webserver.metronAgentsHandler)
-	http.HandleFunc("/syslog_drains", webserver.syslogDrainBindersHandler)
-	http.HandleFunc("/tps_watchers", webserver.tpsWatcherHandler)
-	http.HandleFunc("/tps_listeners", webserver.tpsListenersHandler)
-	http.HandleFunc("/stagers", webserver.stagerHandler)
-	http.HandleFunc("/ssh_proxies", webserver.sshProxyHandler)
-	http.HandleFunc("/senders", webserver.senderHandler)
-	http.HandleFunc("/route_emitters", webserver.routeEmitterHandler)
-	http.HandleFunc("/reps", webserver.repHandler)
-	http.HandleFunc("/receptors", webserver.receptorHandler)
-	http.HandleFunc("/nsync_listeners", webserver.nsyncListenerHandler)
-	http.HandleFunc("/nsync_bulkers", webserver.nsyncBulkerHandler)
-	http.HandleFunc("/garden_linuxs", webserver.gardenLinuxHandler)
-	http.HandleFunc("/file_servers", webserver.fileServersHandler)
-	http.HandleFunc("/fetchers", webserver.fetcherHandler)
-	http.HandleFunc("/convergers", webserver.convergerHandler)
-	http.HandleFunc("/cc_uploaders", webserver.ccUploaderHandler)
-	http.HandleFunc("/bbs", webserver.bbsHandler)
-	http.HandleFunc("/auctioneers", webserver.auctioneerHandler)
-	http.HandleFunc("/etcds", webserver.etcdsHandler)
-	http.HandleFunc("/doppler_servers", webserver.dopplerServersHandler)
-	http.HandleFunc("/cloud_controllers", webserver.cloudControllersHandler)
-	http.HandleFunc("/traffic_controllers", webserver.trafficControllersHandler)
-	http.HandleFunc("/gorouters", webserver.gorouterHandler)
+	webserver.handleFunc("/token", webserver.tokenHandler)
+	webserver.handleResource("/metron_agents", metronAgentOrigin, webserver.metronAgentsHandler)
+	webserver.handleResource("/syslog_drains", syslogDrainBinderOrigin, webserver.syslogDrainBindersHandler)
+	webserver.handleResource("/tps_watchers", tpsWatcherOrigin, webserver.tpsWatcherHandler)
+	webserver.handleResource("/tps_listeners", tpsListenerOrigin, webserver.tpsListenersHandler)
+	webserver.handleResource("/stagers", stagerOrigin, webserver.stagerHandler)
+	webserver.handleResource("/ssh_proxies", sshProxyOrigin, webserver.sshProxyHandler)
+	webserver.handleResource("/senders", senderOrigin, webserver.senderHandler)
+	webserver.handleResource("/route_emitters", routeEmitterOrigin, webserver.routeEmitterHandler)
+	webserver.handleResource("/reps", repOrigin, webserver.repHandler)
+	webserver.handleResource("/receptors", receptorOrigin, webserver.receptorHandler)
+	webserver.handleResource("/nsync_listeners", nsyncListenerOrigin, webserver.nsyncListenerHandler)
+	webserver.handleResource("/nsync_bulkers", nsyncBulkerOrigin, webserver.nsyncBulkerHandler)
+	webserver.handleResource("/garden_linuxs", gardenLinuxOrigin, webserver.gardenLinuxHandler)
+	webserver.handleResource("/file_servers", fileServerOrigin, webserver.fileServersHandler)
+	webserver.handleResource("/fetchers", fetcherOrigin, webserver.fetcherHandler)
+	webserver.handleResource("/convergers", convergerOrigin, webserver.convergerHandler)
+	webserver.handleResource("/cc_uploaders", ccUploaderOrigin, webserver.ccUploaderHandler)
+	webserver.handleResource("/bbs", bbsOrigin, webserver.bbsHandler)
+	webserver.handleResource("/auctioneers", auctioneerOrigin, webserver.auctioneerHandler)
+	webserver.handleResource("/etcds", etcdOrigin, webserver.etcdsHandler)
+	webserver.handleResource("/doppler_servers", dopplerServerOrigin, webserver.dopplerServersHandler)
+	webserver.handleResource("/cloud_controllers", cloudControllerOrigin, webserver.cloudControllersHandler)
+	webserver.handleResource("/traffic_controllers", trafficControllerOrigin, webserver.trafficControllersHandler)
+	webserver.handleResource("/gorouters", goRouterOrigin, webserver.gorouterHandler)
+	webserver.handleFunc("/alerts", webserver.alertsHandler)
+	webserver.handleFunc("/check", webserver.checkHandler)
+	webserver.handleFunc("/kpis", webserver.kpisHandler)
+	webserver.handleFunc("/capacity", webserver.capacityHandler)
+	webserver.handleFunc("/admin/tokens", webserver.tokenCountsHandler)
+	webserver.handleFunc("/admin/ratelimits", webserver.rateLimitsHandler)
+	webserver.handleFunc("/internal/stats", webserver.statsHandler)
+	webserver.handleFunc("/health", webserver.healthHandler)
+	webserver.handleFunc("/ready", webserver.readyHandler)
+
+	listeners, err := createListeners(config, webserver.routes)
+	if err != nil {
+		logger.Fatalf("Error loading listeners: %s", err.Error())
+	}
+	webserver.listeners = listeners
+
+	apiClients, err := createAPIClients(config, webserver.routes)
+	if err != nil {
+		logger.Fatalf("Error loading API clients: %s", err.Error())
+	}
+	webserver.apiClients = apiClients
+	
+	if config.ClientCertificates.Enabled && !webserver.usesSSL() {
+		logger.Fatal("Client certificates require WebServerUseSSL or a listener with UseSSL")
+	}
+	
+	webserver.tlsConfig, err = createTLSConfig(&config.ClientCertificates, logger)
+	if err != nil {
+		logger.Fatalf("Error loading client certificate configuration: %s", err.Error())
+	}
+	
+	err = applyTLSPolicy(webserver.tlsConfig, &config.HTTPServer)
+	if err != nil {
+		logger.Fatalf("Error loading TLS policy: %s", err.Error())
+	}
+	
+	if config.RateLimit.Enabled {
+		webserver.limiter = ratelimit.New(&config.RateLimit, nil)
+	}
+	
+	if config.Audit.Enabled {
+		webserver.auditLogger, err = audit.New(&config.Audit)
+		if err != nil {
+			logger.Fatalf("Error opening audit log: %s", err.Error())
+		}
+		logger.Infof("Writing audit events to %s", config.Audit.Output)
+	}
+	
+	if config.Alerting.Enabled {
+		webserver.alerts = createAlertEngine(config, logger)
+	}
+	
+	if !config.DisableAccessControl {
+		validator, err := uaaauth.New(config.UAAURL, config.InsecureSSLSkipVerify, &config.APIAuthentication, logger)
+		if err != nil {
+			logger.Fatalf("Error creating UAA validator: %s", err.Error())
+		}
+		webserver.validator = validator
+		
+		if config.APIAuthentication.AcceptBearerTokens {
+			webserver.tokenVerifier, err = uaaauth.NewTokenVerifier(config.UAAURL, config.InsecureSSLSkipVerify, &config.APIAuthentication, logger)
+			if err != nil {
+				logger.Fatalf("Error creating UAA token verifier: %s", err.Error())
+			}
+		}
+	}
 
 	return &webserver
 }
 
 //Start starts webserver listening
 func (webserver *WebServer) Start(keyLocation string, certLocation string) <-chan error {
-	webserver.logger.Infof("Start listening on port %v", webserver.config.WebServerPort)
-	errors := make(chan error, 1)
+	if webserver.alerts != nil {
+		webserver.evaluation.Add(1)
+		go webserver.runAlertEvaluation()
+	}
+	
+	errors := make(chan error, len(webserver.listeners))
 	go func() {
 		defer close(errors)
-		if webserver.config.WebServerUseSSL {
-		errors <- http.ListenAndServeTLS(fmt.Sprintf(":%v", webserver.config.WebServerPort), getAbsolutePath(certLocation, webserver.logger), getAbsolutePath(keyLocation, webserver.logger), nil)
-		} else {
-			errors <- http.ListenAndServe(fmt.Sprintf(":%v",webserver.config.WebServerPort), nil)
+		if webserver.usesSSL() {
+			certPath := getAbsolutePath(certLocation, webserver.logger)
+			keyPath := getAbsolutePath(keyLocation, webserver.logger)
+			if err := webserver.ensureCertificate(certPath, keyPath); err != nil {
+				errors <- fmt.Errorf("Unable to generate self-signed TLS certificate: %s", err)
+				return
+			}
+			
+			certificates, err := certloader.New(certPath, keyPath, webserver.logger)
+			if err != nil {
+				errors <- fmt.Errorf("Unable to load TLS certificate: %s", err)
+				return
+			}
+			webserver.certificates = certificates
+			certificates.Watch(time.Duration(webserver.config.CertificateReloadSeconds) * time.Second)
+			defer certificates.Stop()
+			
+			webserver.tlsConfig.GetCertificate = certificates.GetCertificate
+		}
+		
+		var wait sync.WaitGroup
+		for _, serverListener := range webserver.listeners {
+			wait.Add(1)
+			go func(serverListener *listener) {
+				defer wait.Done()
+				if err := webserver.serve(serverListener); err != nil {
+					errors <- err
+				}
+			}(serverListener)
 		}
+		wait.Wait()
 	}()
 	return errors
 }
 
-//TokenTimeout is a callback for when a token timesout to remove
+//ServeHTTP serves every route like the default listener, authentication is required
+func (webserver *WebServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	webserver.router.ServeHTTP(w, r)
+}
+
+//Shutdown stops the listeners, waiting for active requests until ctx is done, then sends the queued alert
+//notifications and closes the audit log. The error channel returned by Start is closed once every listener has stopped
+func (webserver *WebServer) Shutdown(ctx context.Context) error {
+	webserver.shutdownOnce.Do(func() {
+		close(webserver.done)
+	})
+	
+	webserver.serverMutex.Lock()
+	servers := webserver.servers
+	webserver.serverMutex.Unlock()
+	
+	var shutdownErr error
+	for _, server := range servers {
+		if err := server.Shutdown(ctx); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	
+	if webserver.alerts != nil {
+		webserver.evaluation.Wait()
+		if err := webserver.closeAlerts(ctx); err != nil && shutdownErr == nil {
+			shutdownErr = err
+		}
+	}
+	
+	if webserver.auditLogger != nil {
+		if err := webserver.auditLogger.Close(); err != nil {
+			webserver.logger.Errorf("Error closing audit log: %s", err.Error())
+		}
+	}
+	
+	webserver.logger.Info("Web server shut down")
+	return shutdownErr
+}
+
+//TokenTimeout is a callback for when a token timesout and is removed from the token store
 func (webserver *WebServer) TokenTimeout(token *webtoken.Token) {
-	webserver.mutext.Lock()
-	webserver.logger.Debugf("Removing token %s", token.TokenValue)
-	delete(webserver.tokens, token.TokenValue)
-	webserver.mutext.Unlock()
+	webserver.forgetBasicSession(token.ID)
+	webserver.logger.Debugf("Removed timed out token %s of user %s", token.ID, token.Username)
+}
+
+//validateCredentials checks configured API clients with a secret first, other logins are validated by UAA unless
+//access control is disabled, then the configured UAA user is the only other login
+func (webserver *WebServer) validateCredentials(username string, password string) (bool, error) {
+	if client, ok := webserver.apiClients[username]; ok && client.secretHash != nil {
+		return client.validSecret(password), nil
+	}
+	
+	if webserver.validator == nil {
+		return username == webserver.config.UAAUsername && password == webserver.config.UAAPassword, nil
+	}
+	
+	return webserver.validator.Validate(username, password)
 }
 
 /**Handlers**/
 func (webserver *WebServer) tokenHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /token request")
+	webserver.logger.Debug("Received /token request")
+	if !webserver.limitRequest(w, r, ipRateLimitKey(r)) {
+		return
+	}
+	
 	if r.Method == "GET" {
 		username := r.Header.Get(headerUsernameKey)
 		password := r.Header.Get(headerPasswordKey)
+		auditCaller(r, username, "")
 
 		//Check for username and password
 		if username == "" || password == "" {
 			webserver.logger.Debug("No username or password in header")
 			w.WriteHeader(http.StatusBadRequest)
 			io.WriteString(w, "username and/or password not found in header")
-		} else {
+		} else if webserver.limitLogin(w, r, username) && webserver.limitRequest(w, r, userRateLimitKey(username)) {
 			//Check validity of username and password
-			if username != webserver.config.UAAUsername || password != webserver.config.UAAPassword {
+			valid, err := webserver.validateCredentials(username, password)
+			if err != nil {
+				webserver.logger.Errorf("Unable to validate credentials of user %s: %s", username, err.Error())
+				w.WriteHeader(http.StatusServiceUnavailable)
+				io.WriteString(w, "Unable to validate Username and/or Password")
+			} else if !valid {
 				webserver.logger.Debugf("Wrong username and password for user %s", username)
+				webserver.recordFailure(loginRateLimitKey(username))
 				w.WriteHeader(http.StatusUnauthorized)
 				io.WriteString(w, "Invalid Username and/or Password")
 			} else {
 				//Successful login
-				token := webtoken.New(webserver.TokenTimeout)
-
-				webserver.mutext.Lock()
-				webserver.tokens[token.TokenValue] = token
-				webserver.mutext.Unlock()
-
-				w.Header().Set(headerTokenKey, token.TokenValue)
+				webserver.recordSuccess(loginRateLimitKey(username))
+				token, tokenString, err := webserver.tokens.Create(username)
+				if err != nil {
+					webserver.logger.Errorf("Unable to generate token for user %s: %s", username, err.Error())
+					w.WriteHeader(http.StatusInternalServerError)
+					io.WriteString(w, "Unable to generate token")
+					return
+				}
+
+				w.Header().Set(headerTokenKey, tokenString)
 				w.WriteHeader(http.StatusOK)
 
-				webserver.logger.Debugf("Successful login generated token <%s>", token.TokenValue)
+				auditCaller(r, username, token.ID)
+				webserver.logger.Debugf("Successful login of user %s generated token <%s>", username, token.ID)
 			}
 		}
+	} else if r.Method == "DELETE" {
+		webserver.revokeToken(w, r)
 	} else {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 		io.WriteString(w, fmt.Sprintf("/token does not support %s http methods", r.Method))
@@ -137,123 +375,123 @@ func (webserver *WebServer) tokenHandler(w http.ResponseWriter, r *http.Request)
 }
 
 func (webserver *WebServer) metronAgentsHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /metron_agents request")
+	webserver.logger.Debug("Received /metron_agents request")
 	webserver.processResourceRequest(metronAgentOrigin, w, r)
 	
 }
 
 func (webserver *WebServer) syslogDrainBindersHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /syslog_drains request")
+	webserver.logger.Debug("Received /syslog_drains request")
 	webserver.processResourceRequest(syslogDrainBinderOrigin, w, r)
 }
 
 func (webserver *WebServer) tpsWatcherHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /tps_watchers request")
+	webserver.logger.Debug("Received /tps_watchers request")
 	webserver.processResourceRequest(tpsWatcherOrigin, w, r)
 }
 
 func (webserver *WebServer) tpsListenersHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /tps_listeners request")
+	webserver.logger.Debug("Received /tps_listeners request")
 	webserver.processResourceRequest(tpsListenerOrigin, w, r)
 }
 
 func (webserver *WebServer) stagerHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /stagers request")
+	webserver.logger.Debug("Received /stagers request")
 	webserver.processResourceRequest(stagerOrigin, w, r)
 }
 
 func (webserver *WebServer) sshProxyHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /ssh_proxies request")
+	webserver.logger.Debug("Received /ssh_proxies request")
 	webserver.processResourceRequest(sshProxyOrigin, w, r)
 }
 
 func (webserver *WebServer) senderHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /senders request")
+	webserver.logger.Debug("Received /senders request")
 	webserver.processResourceRequest(senderOrigin, w, r)
 }
 
 func (webserver *WebServer) routeEmitterHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /route_emitters request")
+	webserver.logger.Debug("Received /route_emitters request")
 	webserver.processResourceRequest(routeEmitterOrigin, w, r)
 }
 
 func (webserver *WebServer) repHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /reps request")
+	webserver.logger.Debug("Received /reps request")
 	webserver.processResourceRequest(repOrigin, w, r)
 }
 
 func (webserver *WebServer) receptorHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /receptors request")
+	webserver.logger.Debug("Received /receptors request")
 	webserver.processResourceRequest(receptorOrigin, w, r)
 }
 
 func (webserver *WebServer) nsyncListenerHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /nsync_listeners request")
+	webserver.logger.Debug("Received /nsync_listeners request")
 	webserver.processResourceRequest(nsyncListenerOrigin, w, r)
 }
 
 func (webserver *WebServer) nsyncBulkerHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /nsync_bulkers request")
+	webserver.logger.Debug("Received /nsync_bulkers request")
 	webserver.processResourceRequest(nsyncBulkerOrigin, w, r)
 }
 
 func (webserver *WebServer) gardenLinuxHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /garden_linuxs request")
+	webserver.logger.Debug("Received /garden_linuxs request")
 	webserver.processResourceRequest(gardenLinuxOrigin, w, r)
 }
 
 func (webserver *WebServer) fileServersHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /file_servers request")
+	webserver.logger.Debug("Received /file_servers request")
 	webserver.processResourceRequest(fileServerOrigin, w, r)
 }
 
 func (webserver *WebServer) fetcherHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /fetchers request")
+	webserver.logger.Debug("Received /fetchers request")
 	webserver.processResourceRequest(fetcherOrigin, w, r)
 }
 
 func (webserver *WebServer) convergerHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /convergers request")
+	webserver.logger.Debug("Received /convergers request")
 	webserver.processResourceRequest(convergerOrigin, w, r)
 }
 
 func (webserver *WebServer) ccUploaderHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /cc_uploaders request")
+	webserver.logger.Debug("Received /cc_uploaders request")
 	webserver.processResourceRequest(ccUploaderOrigin, w, r)
 }
 
 func (webserver *WebServer) bbsHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /bbs request")
+	webserver.logger.Debug("Received /bbs request")
 	webserver.processResourceRequest(bbsOrigin, w, r)
 }
 
 func (webserver *WebServer) auctioneerHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /auctioneers request")
+	webserver.logger.Debug("Received /auctioneers request")
 	webserver.processResourceRequest(auctioneerOrigin, w, r)
 }
 
 func (webserver *WebServer) etcdsHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /etcds request")
+	webserver.logger.Debug("Received /etcds request")
 	webserver.processResourceRequest(etcdOrigin, w, r)
 }
 
 func (webserver *WebServer) dopplerServersHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /doppler_servers request")
+	webserver.logger.Debug("Received /doppler_servers request")
 	webserver.processResourceRequest(dopplerServerOrigin, w, r)
 }
 
 func (webserver *WebServer) cloudControllersHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /cloud_controllers request")
+	webserver.logger.Debug("Received /cloud_controllers request")
 	webserver.processResourceRequest(cloudControllerOrigin, w, r)
 }
 
 func (webserver *WebServer) trafficControllersHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /traffic_controllers request")
+	webserver.logger.Debug("Received /traffic_controllers request")
 	webserver.processResourceRequest(trafficControllerOrigin, w, r)
 }
 
 func (webserver *WebServer) gorouterHandler(w http.ResponseWriter, r *http.Request) {
-	webserver.logger.Info("Received /gorouters request")
+	webserver.logger.Debug("Received /gorouters request")
 	webserver.processResourceRequest(goRouterOrigin, w, r)
 }
 
@@ -264,7 +502,10 @@ func (webserver *WebServer) CacheEnvelope(envelope *events.Envelope) {
 	webserver.mutext.Lock()
 	defer webserver.mutext.Unlock()
 	
-	key := createEnvelopeKey(envelope)
+	webserver.lastEnvelope = time.Now()
+	webserver.countEnvelope(envelope)
+	
+	key := CreateEnvelopeKey(envelope)
 	webserver.logger.Debugf("Caching envelope origin %s with key %s", envelope.GetOrigin(), key)
 	
 	//Find origin map
@@ -293,7 +534,27 @@ func (webserver *WebServer) CacheEnvelope(envelope *events.Envelope) {
 	}
 	
 	addMetric(envelope, resource.ValueMetrics, resource.CounterMetrics, webserver.logger)
+	addTags(envelope, &resource)
 	resourceCache[key] = resource
+	
+	webserver.trackCounterRate(envelope)
+}
+
+//Snapshot returns a copy of the cached resources grouped by origin
+func (webserver *WebServer) Snapshot() map[string][]Resource {
+	webserver.mutext.Lock()
+	defer webserver.mutext.Unlock()
+	
+	snapshot := make(map[string][]Resource, len(webserver.cache))
+	for origin, resourceMap := range webserver.cache {
+		resources := make([]Resource, 0, len(resourceMap))
+		for _, resource := range resourceMap {
+			resources = append(resources, copyResource(resource))
+		}
+		snapshot[origin] = resources
+	}
+	
+	return snapshot
 }
 
 //ClearCache clears out cache for server
@@ -303,30 +564,19 @@ func (webserver *WebServer) ClearCache() {
 	defer webserver.mutext.Unlock()
 	
 	webserver.cache = make(map[string]map[string]Resource)
+	webserver.pruneCounterRates(time.Now())
+	webserver.updateEnvelopeRate(time.Now())
 }
 
 func (webserver *WebServer) processResourceRequest(originType string, w http.ResponseWriter, r *http.Request) {
+	if !webserver.authorizeRequest(w, r) {
+		return
+	}
+	
 	webserver.mutext.Lock()
 	defer webserver.mutext.Unlock()
 	
-	if r.Method == "GET" {
-		tokenString := r.Header.Get(headerTokenKey)
-		
-		token := webserver.tokens[tokenString]
-		
-		if token == nil || !token.IsTokenValid() {
-			webserver.logger.Debugf("Invalid token %s supplied", tokenString)
-			w.WriteHeader(http.StatusUnauthorized)
-			io.WriteString(w, fmt.Sprintf("Invalid token %s supplied", tokenString))
-		} else {
-			webserver.logger.Debugf("Valid token %s supplied", tokenString)
-			token.UseToken()
-			webserver.sendOriginBytes(originType, w)
-		}		
-	} else {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		io.WriteString(w, fmt.Sprintf("Unsupported http method %s", r.Method))
-	}
+	webserver.sendOriginBytes(originType, w)
 }
 
 func (webserver *WebServer) sendOriginBytes(originType string, w http.ResponseWriter) {
@@ -0,0 +1,136 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/alerting"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+const (
+	defaultAlertEvaluationIntervalSeconds = 10
+)
+
+func createAlertEngine(config *nozzleconfiguration.NozzleConfiguration, logger *gosteno.Logger) *alerting.Engine {
+	//Metrics are missing for a while after every cache flush, so only treat data as gone after two flushes
+	staleAfter := 2 * time.Duration(config.MetricCacheDurationSeconds) * time.Second
+
+	engine, err := alerting.New(&config.Alerting, staleAfter, logger)
+	if err != nil {
+		logger.Fatalf("Error creating alert rules: %s", err.Error())
+	}
+
+	logger.Infof("Evaluating %d alert rules", len(config.Alerting.Rules))
+	return engine
+}
+
+func (webserver *WebServer) runAlertEvaluation() {
+	defer webserver.evaluation.Done()
+
+	interval := webserver.config.Alerting.EvaluationIntervalSeconds
+	if interval == 0 {
+		interval = defaultAlertEvaluationIntervalSeconds
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			webserver.evaluateAlerts(time.Now())
+		case <-webserver.done:
+			return
+		}
+	}
+}
+
+//closeAlerts sends the queued notifications, it gives up on them when ctx is done
+func (webserver *WebServer) closeAlerts(ctx context.Context) error {
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		webserver.alerts.Close()
+	}()
+
+	select {
+	case <-closed:
+		return nil
+	case <-ctx.Done():
+		webserver.logger.Warn("Queued alert notifications were not sent before the shutdown deadline")
+		return ctx.Err()
+	}
+}
+
+func (webserver *WebServer) evaluateAlerts(now time.Time) {
+	webserver.mutext.Lock()
+	var instances []alerting.Instance
+	for _, origin := range webserver.alerts.Origins() {
+		for _, resource := range webserver.cache[origin] {
+			instances = append(instances, createAlertInstance(origin, resource))
+		}
+	}
+	webserver.mutext.Unlock()
+
+	webserver.alerts.Evaluate(instances, now)
+}
+
+func createAlertInstance(origin string, resource Resource) alerting.Instance {
+	metrics := make(map[string]float64, len(resource.ValueMetrics)+len(resource.CounterMetrics))
+	for name, value := range resource.CounterMetrics {
+		metrics[name] = value
+	}
+
+	for name, value := range resource.ValueMetrics {
+		metrics[name] = value
+	}
+
+	return alerting.Instance{
+		Origin:     origin,
+		Deployment: resource.Deployment,
+		Job:        resource.Job,
+		Index:      resource.Index,
+		IP:         resource.IP,
+		Metrics:    metrics,
+	}
+}
+
+func (webserver *WebServer) alertsHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /alerts request")
+
+	client, ok := webserver.authorizeClient(w, r)
+	if !ok {
+		return
+	}
+
+	if webserver.alerts == nil {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Alerting is not enabled")
+		return
+	}
+
+	alerts := make([]alerting.Alert, 0)
+	for _, alert := range webserver.alerts.Alerts() {
+		if client.allowedOrigin(alert.Origin) {
+			alerts = append(alerts, alert)
+		}
+	}
+
+	messageBytes, _ := json.Marshal(alerts)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /alerts call: %s", err.Error())
+	}
+}
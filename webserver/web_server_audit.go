@@ -0,0 +1,122 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/audit"
+)
+
+type auditContextKey struct{}
+
+//auditRecord collects what the handlers learn about the caller of a request
+type auditRecord struct {
+	user    string
+	tokenID string
+}
+
+//auditResponseWriter remembers the status code and size of the response
+type auditResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (writer *auditResponseWriter) WriteHeader(status int) {
+	writer.status = status
+	writer.ResponseWriter.WriteHeader(status)
+}
+
+func (writer *auditResponseWriter) Write(b []byte) (int, error) {
+	written, err := writer.ResponseWriter.Write(b)
+	writer.bytes += int64(written)
+	return written, err
+}
+
+//Flush passes flushes through to writers that support them, so handlers can stream through the audit
+func (writer *auditResponseWriter) Flush() {
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+//handleFunc adds handler for pattern to the router and the routes of the listeners, counted in the self stats and
+//audited when the audit log is enabled
+func (webserver *WebServer) handleFunc(pattern string, handler http.HandlerFunc) {
+	audited := webserver.auditHandler(handler)
+	webserver.routes = append(webserver.routes, route{pattern: pattern, handler: audited})
+	webserver.router.HandleFunc(pattern, audited)
+}
+
+//handleResource adds a handler serving the cached resources of origin, API clients allowed that origin may read it
+func (webserver *WebServer) handleResource(pattern string, origin string, handler http.HandlerFunc) {
+	webserver.handleFunc(pattern, handler)
+	webserver.routes[len(webserver.routes)-1].origin = origin
+}
+
+func (webserver *WebServer) auditHandler(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writer := &auditResponseWriter{ResponseWriter: w, status: http.StatusOK}
+		if webserver.auditLogger == nil {
+			handler(writer, r)
+			webserver.countRequest(r, writer.status)
+			return
+		}
+
+		start := time.Now()
+		record := &auditRecord{}
+		handler(writer, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, record)))
+		webserver.countRequest(r, writer.status)
+
+		event := audit.Event{
+			Time:      start.UTC(),
+			Type:      auditEventType(r, writer.status),
+			ClientIP:  clientIP(r),
+			User:      record.user,
+			TokenID:   record.tokenID,
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    writer.status,
+			Bytes:     writer.bytes,
+			LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+		}
+
+		if err := webserver.auditLogger.Log(event); err != nil {
+			webserver.logger.Errorf("Unable to write audit event for %s request: %s", r.URL.Path, err.Error())
+		}
+	}
+}
+
+//auditCaller records the user and token ID hash of the request for its audit event
+func auditCaller(r *http.Request, user string, tokenID string) {
+	record, ok := r.Context().Value(auditContextKey{}).(*auditRecord)
+	if !ok {
+		return
+	}
+
+	if user != "" {
+		record.user = user
+	}
+
+	if tokenID != "" {
+		record.tokenID = tokenID
+	}
+}
+
+func auditEventType(r *http.Request, status int) string {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		status == http.StatusTooManyRequests || status == http.StatusMethodNotAllowed:
+		return audit.EventDenied
+	case r.URL.Path == "/token" && r.Method == "GET" && status == http.StatusOK:
+		return audit.EventTokenIssued
+	case r.URL.Path == "/token" && r.Method == "DELETE" && status == http.StatusNoContent:
+		return audit.EventTokenRevoked
+	default:
+		return audit.EventRequest
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/audit"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
+)
+
+func TestAuditEvents(t *testing.T) {
+	var buffer bytes.Buffer
+	server := createAuthWebServer()
+	server.auditLogger = audit.NewWithWriter(&buffer)
+
+	server.cache[goRouterOrigin] = map[string]Resource{}
+	token, tokenString, _ := server.tokens.Create("user")
+	handler := server.auditHandler(server.gorouterHandler)
+
+	request := createAuthRequest("Bearer " + tokenString)
+	request.RemoteAddr = "10.0.0.1:50000"
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+
+	request = createAuthRequest("Bearer invalid")
+	request.RemoteAddr = "10.0.0.2:50000"
+	handler(httptest.NewRecorder(), request)
+
+	var events []audit.Event
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		var event audit.Event
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("Expected JSON audit event, but received %s: %s", line, err.Error())
+		}
+		events = append(events, event)
+	}
+
+	t.Log("Check if every request is audited... (expecting events: 2)")
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, but received %d", len(events))
+	}
+
+	read := events[0]
+	t.Log("Check if resource read is audited with user and token... (expecting event: request)")
+	if read.Type != audit.EventRequest || read.User != "user" || read.TokenID != token.ID || read.ClientIP != "10.0.0.1" ||
+		read.Path != "/gorouters" || read.Status != http.StatusOK || read.Bytes != int64(recorder.Body.Len()) {
+		t.Errorf("Expected request of user with token %s and %d bytes, but received %+v", token.ID, recorder.Body.Len(), read)
+	}
+
+	denied := events[1]
+	t.Log("Check if invalid token is audited as denial... (expecting event: denied)")
+	if denied.Type != audit.EventDenied || denied.Status != http.StatusUnauthorized || denied.TokenID != webtoken.HashTokenString("invalid") || denied.User != "" {
+		t.Errorf("Expected denial of token %s, but received %+v", webtoken.HashTokenString("invalid"), denied)
+	}
+}
+
+func TestAuditEventType(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		status int
+		event  string
+	}{
+		{"GET", "/token", http.StatusOK, audit.EventTokenIssued},
+		{"DELETE", "/token", http.StatusNoContent, audit.EventTokenRevoked},
+		{"GET", "/token", http.StatusUnauthorized, audit.EventDenied},
+		{"GET", "/kpis", http.StatusForbidden, audit.EventDenied},
+		{"GET", "/kpis", http.StatusTooManyRequests, audit.EventDenied},
+		{"GET", "/kpis", http.StatusOK, audit.EventRequest},
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest(c.method, "https://localhost"+c.path, nil)
+
+		t.Logf("Check event type of %s %s with status %d... (expecting event: %s)", c.method, c.path, c.status, c.event)
+		if event := auditEventType(request, c.status); event != c.event {
+			t.Errorf("Expected event %s, but received %s", c.event, event)
+		}
+	}
+}
+
+func TestAuditFlush(t *testing.T) {
+	var buffer bytes.Buffer
+	server := createAuthWebServer()
+
+	for _, auditLogger := range []*audit.Logger{nil, audit.NewWithWriter(&buffer)} {
+		server.auditLogger = auditLogger
+		handler := server.auditHandler(func(w http.ResponseWriter, r *http.Request) {
+			if flusher, ok := w.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		})
+
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "https://localhost/kpis", nil)
+		handler(recorder, request)
+
+		t.Logf("Check if flushes pass through with audit log %v... (expecting flushed response)", auditLogger != nil)
+		if !recorder.Flushed {
+			t.Error("Expected flushed response, but it was not flushed")
+		}
+	}
+}
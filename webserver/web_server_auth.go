@@ -0,0 +1,238 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/uaaauth"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
+)
+
+const (
+	headerAuthorizationKey   = "Authorization"
+	headerAuthenticateKey    = "WWW-Authenticate"
+	basicScheme              = "Basic"
+	bearerScheme             = "Bearer"
+	authenticationRealm      = "bluemedora-firehose-nozzle"
+	bearerErrorInvalidToken  = "invalid_token"
+	bearerErrorInvalidFormat = "invalid_request"
+)
+
+//authorizeRequest must be called without the mutex held, it answers the request itself when it is not authorized
+func (webserver *WebServer) authorizeRequest(w http.ResponseWriter, r *http.Request) bool {
+	_, ok := webserver.authorizeClient(w, r)
+	return ok
+}
+
+//authorizeClient is authorizeRequest for handlers that serve several origins, they restrict them to the returned client
+func (webserver *WebServer) authorizeClient(w http.ResponseWriter, r *http.Request) (*apiClient, bool) {
+	user, ok := webserver.authenticateRequest(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	return webserver.authorizeEndpoint(w, r, user)
+}
+
+//authenticateRequest returns the user of the client certificate, Authorization header or token header of the request.
+//Requests over TLS need a client certificate when they are enabled
+func (webserver *WebServer) authenticateRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != "GET" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, fmt.Sprintf("Unsupported http method %s", r.Method))
+		return "", false
+	}
+
+	if !webserver.limitRequest(w, r, ipRateLimitKey(r)) {
+		return "", false
+	}
+
+	if listener := requestListener(r); listener != nil && !listener.requireAuthentication {
+		return "", true
+	}
+
+	scheme, credentials := parseAuthorization(r)
+	certificateUser, hasCertificate := webserver.certificateUser(r)
+
+	if webserver.config.ClientCertificates.Enabled && r.TLS != nil && !hasCertificate {
+		webserver.logger.Debug("No client certificate supplied")
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Client certificate required")
+		return "", false
+	}
+
+	var user string
+	var ok bool
+	switch {
+	case hasCertificate && !webserver.config.ClientCertificates.RequireToken:
+		webserver.logger.Debugf("Valid client certificate of %s supplied", certificateUser)
+		auditCaller(r, certificateUser, "")
+		user, ok = certificateUser, true
+	case strings.EqualFold(scheme, basicScheme):
+		user, ok = webserver.authenticateBasic(w, r)
+	case strings.EqualFold(scheme, bearerScheme) && webserver.tokenVerifier != nil && isJWT(credentials):
+		user, ok = webserver.authenticateBearerToken(w, r, credentials)
+	case strings.EqualFold(scheme, bearerScheme):
+		user, ok = webserver.authenticateToken(w, r, credentials)
+	case scheme != "":
+		webserver.logger.Debugf("Unsupported authorization scheme %s supplied", scheme)
+		webserver.challenge(w, bearerErrorInvalidFormat, fmt.Sprintf("Unsupported authorization scheme %s", scheme))
+	default:
+		user, ok = webserver.authenticateToken(w, r, r.Header.Get(headerTokenKey))
+	}
+
+	if !ok {
+		return "", false
+	}
+
+	return user, webserver.limitRequest(w, r, userRateLimitKey(user))
+}
+
+//authenticateToken accepts tokens from /token in the token header or as bearer token
+func (webserver *WebServer) authenticateToken(w http.ResponseWriter, r *http.Request, tokenString string) (string, bool) {
+	token, err := webserver.tokens.Use(tokenString)
+	if err != nil {
+		webserver.logger.Debugf("Invalid token %s supplied", webtoken.HashTokenString(tokenString))
+		if tokenString != "" {
+			auditCaller(r, "", webtoken.HashTokenString(tokenString))
+		}
+		webserver.challenge(w, bearerErrorInvalidToken, "Invalid token supplied")
+		return "", false
+	}
+
+	auditCaller(r, token.Username, token.ID)
+	webserver.logger.Debugf("Valid token %s of user %s supplied", token.ID, token.Username)
+	return token.Username, true
+}
+
+func (webserver *WebServer) authenticateBearerToken(w http.ResponseWriter, r *http.Request, bearerToken string) (string, bool) {
+	auditCaller(r, "", webtoken.HashTokenString(bearerToken))
+	claims, err := webserver.tokenVerifier.Verify(bearerToken)
+	if err != nil {
+		webserver.logger.Debugf("Invalid bearer token supplied: %s", err.Error())
+		webserver.challenge(w, bearerErrorInvalidToken, fmt.Sprintf("Invalid bearer token supplied: %s", err.Error()))
+		return "", false
+	}
+
+	auditCaller(r, claims.Name(), "")
+	webserver.logger.Debugf("Valid bearer token of %s supplied", claims.Name())
+	return claims.Name(), true
+}
+
+//authenticateBasic logs in like /token on the first request and reuses that session token while it is valid, so
+//clients sending basic credentials with every request do not cause a login each time
+func (webserver *WebServer) authenticateBasic(w http.ResponseWriter, r *http.Request) (string, bool) {
+	username, password, ok := r.BasicAuth()
+	if !ok || username == "" || password == "" {
+		webserver.logger.Debug("Malformed basic authorization supplied")
+		webserver.challenge(w, bearerErrorInvalidFormat, "Malformed basic authorization supplied")
+		return "", false
+	}
+
+	sessionKey := uaaauth.HashCredentials(username, password)
+
+	webserver.sessionMutex.Lock()
+	tokenID, hasSession := webserver.basicSessions[sessionKey]
+	webserver.sessionMutex.Unlock()
+
+	if hasSession {
+		if _, err := webserver.tokens.UseID(tokenID); err == nil {
+			auditCaller(r, username, tokenID)
+			webserver.logger.Debugf("Valid basic authorization session %s of user %s supplied", tokenID, username)
+			return username, true
+		}
+
+		webserver.sessionMutex.Lock()
+		delete(webserver.basicSessions, sessionKey)
+		webserver.sessionMutex.Unlock()
+	}
+
+	//The request rate of username is limited by authenticateRequest, only its lockout is checked here
+	if !webserver.limitLogin(w, r, username) {
+		return "", false
+	}
+
+	valid, err := webserver.validateCredentials(username, password)
+	if err != nil {
+		webserver.logger.Errorf("Unable to validate credentials of user %s: %s", username, err.Error())
+		w.WriteHeader(http.StatusServiceUnavailable)
+		io.WriteString(w, "Unable to validate Username and/or Password")
+		return "", false
+	}
+
+	if !valid {
+		webserver.logger.Debugf("Wrong basic authorization for user %s", username)
+		auditCaller(r, username, "")
+		webserver.recordFailure(loginRateLimitKey(username))
+		webserver.challenge(w, "", "Invalid Username and/or Password")
+		return "", false
+	}
+
+	webserver.recordSuccess(loginRateLimitKey(username))
+	token, _, err := webserver.tokens.Create(username)
+	if err != nil {
+		webserver.logger.Errorf("Unable to generate session for user %s: %s", username, err.Error())
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "Unable to generate session")
+		return "", false
+	}
+
+	webserver.sessionMutex.Lock()
+	webserver.basicSessions[sessionKey] = token.ID
+	webserver.sessionMutex.Unlock()
+
+	auditCaller(r, username, token.ID)
+	webserver.logger.Debugf("Basic authorization of user %s started session %s", username, token.ID)
+	return username, true
+}
+
+//forgetBasicSession removes the basic authorization session using the token with tokenID
+func (webserver *WebServer) forgetBasicSession(tokenID string) {
+	webserver.sessionMutex.Lock()
+	defer webserver.sessionMutex.Unlock()
+
+	for sessionKey, sessionTokenID := range webserver.basicSessions {
+		if sessionTokenID == tokenID {
+			delete(webserver.basicSessions, sessionKey)
+			return
+		}
+	}
+}
+
+//challenge answers with 401 and the WWW-Authenticate schemes the client can use, bearerError is optional
+func (webserver *WebServer) challenge(w http.ResponseWriter, bearerError string, message string) {
+	bearerChallenge := fmt.Sprintf("%s realm=%q", bearerScheme, authenticationRealm)
+	if bearerError != "" {
+		bearerChallenge = fmt.Sprintf("%s, error=%q, error_description=%q", bearerChallenge, bearerError, message)
+	}
+
+	w.Header().Add(headerAuthenticateKey, fmt.Sprintf("%s realm=%q, charset=\"UTF-8\"", basicScheme, authenticationRealm))
+	w.Header().Add(headerAuthenticateKey, bearerChallenge)
+	w.WriteHeader(http.StatusUnauthorized)
+	io.WriteString(w, message)
+}
+
+//parseAuthorization splits the Authorization header into scheme and credentials
+func parseAuthorization(r *http.Request) (string, string) {
+	authorization := strings.TrimSpace(r.Header.Get(headerAuthorizationKey))
+	if authorization == "" {
+		return "", ""
+	}
+
+	parts := strings.SplitN(authorization, " ", 2)
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+
+	return parts[0], strings.TrimSpace(parts[1])
+}
+
+//isJWT tells UAA access tokens apart from tokens issued by /token
+func isJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
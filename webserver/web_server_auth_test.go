@@ -0,0 +1,126 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
+)
+
+func TestBasicAuthorization(t *testing.T) {
+	server := createAuthWebServer()
+
+	for i := 0; i < 2; i++ {
+		request := createAuthRequest("")
+		request.SetBasicAuth("user", "password")
+
+		t.Logf("Check if basic authorization request %d is authenticated... (expecting user: user)", i+1)
+		if user, _, ok := authenticateTestRequest(server, request); !ok || user != "user" {
+			t.Errorf("Expected user user, but received %s", user)
+		}
+	}
+
+	t.Log("Check if basic authorization requests share one session... (expecting sessions: 1)")
+	if counts := server.tokens.Counts(); counts["user"] != 1 {
+		t.Errorf("Expected 1 session of user, but received %v", counts)
+	}
+
+	request := createAuthRequest("")
+	request.SetBasicAuth("user", "wrong-password")
+
+	t.Logf("Check if wrong basic authorization is challenged... (expecting status code: %v)", http.StatusUnauthorized)
+	_, recorder, ok := authenticateTestRequest(server, request)
+	if ok || recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, recorder.Code)
+	}
+
+	checkChallenges(t, recorder, "")
+}
+
+func TestBasicSessionTimeout(t *testing.T) {
+	server := createAuthWebServer()
+	now := time.Now()
+	server.tokens = webtoken.NewStore(time.Minute, 0, func() time.Time { return now }, server.TokenTimeout)
+
+	request := createAuthRequest("")
+	request.SetBasicAuth("user", "password")
+	authenticateTestRequest(server, request)
+
+	t.Log("Check if basic authorization started a session... (expecting sessions: 1)")
+	if len(server.basicSessions) != 1 {
+		t.Fatalf("Expected 1 basic session, but received %d", len(server.basicSessions))
+	}
+
+	now = now.Add(2 * time.Minute)
+	server.tokens.Expire()
+
+	t.Log("Check if the session of the timed out token is removed... (expecting sessions: 0)")
+	if len(server.basicSessions) != 0 {
+		t.Errorf("Expected no basic sessions, but received %d", len(server.basicSessions))
+	}
+}
+
+func TestBearerAuthorization(t *testing.T) {
+	server := createAuthWebServer()
+	_, tokenString, _ := server.tokens.Create("user")
+
+	t.Log("Check if token from /token is accepted as bearer token... (expecting user: user)")
+	if user, _, ok := authenticateTestRequest(server, createAuthRequest("Bearer "+tokenString)); !ok || user != "user" {
+		t.Errorf("Expected user user, but received %s", user)
+	}
+
+	t.Logf("Check if invalid bearer token is challenged... (expecting status code: %v)", http.StatusUnauthorized)
+	_, recorder, ok := authenticateTestRequest(server, createAuthRequest("Bearer invalid"))
+	if ok || recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, recorder.Code)
+	}
+	checkChallenges(t, recorder, `error="invalid_token"`)
+
+	t.Logf("Check if unsupported scheme is challenged... (expecting status code: %v)", http.StatusUnauthorized)
+	_, recorder, ok = authenticateTestRequest(server, createAuthRequest("Digest username=\"user\""))
+	if ok || recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, recorder.Code)
+	}
+	checkChallenges(t, recorder, `error="invalid_request"`)
+}
+
+func checkChallenges(t *testing.T, recorder *httptest.ResponseRecorder, bearerError string) {
+	challenges := recorder.Header()[http.CanonicalHeaderKey(headerAuthenticateKey)]
+
+	t.Logf("Check if Basic and Bearer challenges are sent... (expecting bearer error: %s)", bearerError)
+	if len(challenges) != 2 || !strings.HasPrefix(challenges[0], "Basic realm=") || !strings.HasPrefix(challenges[1], "Bearer realm=") || !strings.Contains(challenges[1], bearerError) {
+		t.Errorf("Expected Basic and Bearer challenges with %s, but received %v", bearerError, challenges)
+	}
+}
+
+func createAuthWebServer() *WebServer {
+	server := createKPIWebServer()
+	server.config = &nozzleconfiguration.NozzleConfiguration{
+		UAAUsername: "user",
+		UAAPassword: "password",
+	}
+	server.tokens = webtoken.NewStore(time.Minute, 0, nil, nil)
+	server.basicSessions = make(map[string]string)
+	return server
+}
+
+func createAuthRequest(authorization string) *http.Request {
+	request, _ := http.NewRequest("GET", "https://localhost/gorouters", nil)
+	if authorization != "" {
+		request.Header.Set(headerAuthorizationKey, authorization)
+	}
+	return request
+}
+
+func authenticateTestRequest(server *WebServer, request *http.Request) (string, *httptest.ResponseRecorder, bool) {
+	recorder := httptest.NewRecorder()
+	user, ok := server.authenticateRequest(recorder, request)
+	return user, recorder, ok
+}
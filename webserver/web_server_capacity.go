@@ -0,0 +1,204 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	defaultIsolationSegmentTag = "placement_tag"
+	sharedIsolationSegment     = "shared"
+
+	capacityRemainingMemory     = "CapacityRemainingMemory"
+	capacityTotalMemory         = "CapacityTotalMemory"
+	capacityRemainingDisk       = "CapacityRemainingDisk"
+	capacityRemainingContainers = "CapacityRemainingContainers"
+)
+
+//CapacityReport is how many chunks of the requested size fit on the Diego cells
+type CapacityReport struct {
+	MemoryMB          float64
+	DiskMB            float64
+	Chunks            int
+	Cells             []CellCapacity
+	Deployments       map[string]*CapacitySummary
+	IsolationSegments map[string]*CapacitySummary
+	IncompleteCells   []string `json:",omitempty"`
+}
+
+//CellCapacity is the remaining capacity of a single cell
+type CellCapacity struct {
+	Deployment          string
+	Job                 string
+	Index               string
+	IP                  string
+	IsolationSegment    string
+	RemainingMemoryMB   float64
+	TotalMemoryMB       float64
+	RemainingDiskMB     float64
+	RemainingContainers float64
+	Chunks              int
+}
+
+//CapacitySummary adds up the cells of a deployment or isolation segment
+type CapacitySummary struct {
+	Cells             int
+	Chunks            int
+	RemainingMemoryMB float64
+	TotalMemoryMB     float64
+}
+
+func (summary *CapacitySummary) add(cell CellCapacity) {
+	summary.Cells++
+	summary.Chunks += cell.Chunks
+	summary.RemainingMemoryMB += cell.RemainingMemoryMB
+	summary.TotalMemoryMB += cell.TotalMemoryMB
+}
+
+func parsePositiveParameter(r *http.Request, name string, required bool) (float64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		if required {
+			return 0, fmt.Errorf("%s parameter is required", name)
+		}
+		return 0, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed <= 0 {
+		return 0, fmt.Errorf("%s must be a positive number", name)
+	}
+
+	return parsed, nil
+}
+
+//calculateCapacity limits the chunks of every cell by remaining memory, disk when requested and containers
+func calculateCapacity(memoryMB float64, diskMB float64, segmentTag string, resources []Resource) CapacityReport {
+	report := CapacityReport{
+		MemoryMB:          memoryMB,
+		DiskMB:            diskMB,
+		Cells:             make([]CellCapacity, 0, len(resources)),
+		Deployments:       make(map[string]*CapacitySummary),
+		IsolationSegments: make(map[string]*CapacitySummary),
+	}
+
+	for _, resource := range resources {
+		remainingMemory, hasMemory := resource.ValueMetrics[capacityRemainingMemory]
+		remainingDisk, hasDisk := resource.ValueMetrics[capacityRemainingDisk]
+		if !hasMemory || (diskMB > 0 && !hasDisk) {
+			report.IncompleteCells = append(report.IncompleteCells, CreateResourceKey(resource))
+			continue
+		}
+
+		cell := CellCapacity{
+			Deployment:        resource.Deployment,
+			Job:               resource.Job,
+			Index:             resource.Index,
+			IP:                resource.IP,
+			IsolationSegment:  resource.Tags[segmentTag],
+			RemainingMemoryMB: remainingMemory,
+			TotalMemoryMB:     resource.ValueMetrics[capacityTotalMemory],
+			RemainingDiskMB:   remainingDisk,
+		}
+
+		if cell.IsolationSegment == "" {
+			cell.IsolationSegment = sharedIsolationSegment
+		}
+
+		chunks := math.Floor(remainingMemory / memoryMB)
+		if diskMB > 0 {
+			chunks = math.Min(chunks, math.Floor(remainingDisk/diskMB))
+		}
+
+		if containers, ok := resource.ValueMetrics[capacityRemainingContainers]; ok {
+			cell.RemainingContainers = containers
+			chunks = math.Min(chunks, containers)
+		}
+
+		cell.Chunks = int(math.Max(chunks, 0))
+		report.Chunks += cell.Chunks
+		report.Cells = append(report.Cells, cell)
+
+		if report.Deployments[cell.Deployment] == nil {
+			report.Deployments[cell.Deployment] = &CapacitySummary{}
+		}
+		report.Deployments[cell.Deployment].add(cell)
+
+		if report.IsolationSegments[cell.IsolationSegment] == nil {
+			report.IsolationSegments[cell.IsolationSegment] = &CapacitySummary{}
+		}
+		report.IsolationSegments[cell.IsolationSegment].add(cell)
+	}
+
+	sort.Sort(byCell(report.Cells))
+	sort.Strings(report.IncompleteCells)
+	return report
+}
+
+type byCell []CellCapacity
+
+func (cells byCell) Len() int      { return len(cells) }
+func (cells byCell) Swap(i, j int) { cells[i], cells[j] = cells[j], cells[i] }
+func (cells byCell) Less(i, j int) bool {
+	return createKey(cells[i].Deployment, cells[i].Job, cells[i].Index, cells[i].IP) <
+		createKey(cells[j].Deployment, cells[j].Job, cells[j].Index, cells[j].IP)
+}
+
+func (webserver *WebServer) capacityHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /capacity request")
+
+	client, ok := webserver.authorizeClient(w, r)
+	if !ok || !webserver.authorizeOrigin(w, client, repOrigin) {
+		return
+	}
+
+	webserver.mutext.Lock()
+	var resources []Resource
+	for _, resource := range webserver.cache[repOrigin] {
+		resources = append(resources, copyResource(resource))
+	}
+	webserver.mutext.Unlock()
+
+	memoryMB, err := parsePositiveParameter(r, "memory_mb", true)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, err.Error())
+		return
+	}
+
+	diskMB, err := parsePositiveParameter(r, "disk_mb", false)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, err.Error())
+		return
+	}
+
+	segmentTag := r.URL.Query().Get("segment_tag")
+	if segmentTag == "" {
+		segmentTag = defaultIsolationSegmentTag
+	}
+
+	if len(resources) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	report := calculateCapacity(memoryMB, diskMB, segmentTag, resources)
+	messageBytes, _ := json.Marshal(report)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(messageBytes)
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /capacity call: %s", err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"testing"
+)
+
+func TestCapacity(t *testing.T) {
+	resources := []Resource{
+		createCellResource("cf", "0", "", 8192, 16384, 40000, 250),
+		createCellResource("cf", "1", "", 3000, 16384, 2500, 250),
+		createCellResource("cf-isolated", "0", "secure", 16384, 16384, 60000, 3),
+		{Deployment: "cf", Job: "cell", Index: "2", IP: "10.0.0.1", ValueMetrics: map[string]float64{}},
+	}
+
+	report := calculateCapacity(1024, 2048, defaultIsolationSegmentTag, resources)
+
+	expectedChunks := []int{8, 1, 3}
+	for i, expected := range expectedChunks {
+		t.Logf("Checking chunks of cell %s/%s... (expected value: %d)", report.Cells[i].Deployment, report.Cells[i].Index, expected)
+		if report.Cells[i].Chunks != expected {
+			t.Errorf("Expected %d chunks, but received %d", expected, report.Cells[i].Chunks)
+		}
+	}
+
+	t.Log("Checking total chunks... (expected value: 12)")
+	if report.Chunks != 12 {
+		t.Errorf("Expected 12 chunks, but received %d", report.Chunks)
+	}
+
+	t.Log("Checking deployment breakdown... (expected value: cf 9, cf-isolated 3)")
+	if report.Deployments["cf"].Chunks != 9 || report.Deployments["cf-isolated"].Chunks != 3 {
+		t.Errorf("Expected cf with 9 and cf-isolated with 3 chunks, but received %+v and %+v", report.Deployments["cf"], report.Deployments["cf-isolated"])
+	}
+
+	t.Log("Checking isolation segment breakdown... (expected value: shared 9, secure 3)")
+	if report.IsolationSegments[sharedIsolationSegment].Chunks != 9 || report.IsolationSegments["secure"].Chunks != 3 {
+		t.Errorf("Expected shared with 9 and secure with 3 chunks, but received %+v and %+v",
+			report.IsolationSegments[sharedIsolationSegment], report.IsolationSegments["secure"])
+	}
+
+	t.Log("Checking cell without capacity metrics is reported as incomplete... (expected value: 1)")
+	if len(report.IncompleteCells) != 1 {
+		t.Errorf("Expected 1 incomplete cell, but received %v", report.IncompleteCells)
+	}
+}
+
+func TestCapacityMemoryOnly(t *testing.T) {
+	resources := []Resource{createCellResource("cf", "0", "", 3000, 16384, 0, 250)}
+
+	report := calculateCapacity(512, 0, defaultIsolationSegmentTag, resources)
+
+	t.Log("Checking disk is ignored without disk_mb... (expected value: 5)")
+	if report.Chunks != 5 {
+		t.Errorf("Expected 5 chunks, but received %d", report.Chunks)
+	}
+}
+
+func createCellResource(deployment string, index string, segment string, remainingMemory float64, totalMemory float64, remainingDisk float64, containers float64) Resource {
+	resource := Resource{
+		Deployment: deployment,
+		Job:        "cell",
+		Index:      index,
+		IP:         "10.0.0.1",
+		ValueMetrics: map[string]float64{
+			capacityRemainingMemory:     remainingMemory,
+			capacityTotalMemory:         totalMemory,
+			capacityRemainingDisk:       remainingDisk,
+			capacityRemainingContainers: containers,
+		},
+		CounterMetrics: make(map[string]float64),
+	}
+
+	if segment != "" {
+		resource.Tags = map[string]string{defaultIsolationSegmentTag: segment}
+	}
+
+	return resource
+}
@@ -0,0 +1,346 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//Check states use the Nagios plugin return codes
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+
+	apiKeyHeader     = "api_key"
+	checkStateHeader = "X-Check-State"
+)
+
+var checkStateNames = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+//Monitoring systems that can only read status codes tell the states apart by these.
+//WARNING is still answered with 200 as 4xx codes would be mistaken for denied requests, its state is in the body and header
+var checkStatusCodes = map[int]int{
+	checkOK:       http.StatusOK,
+	checkWarning:  http.StatusOK,
+	checkCritical: http.StatusServiceUnavailable,
+	checkUnknown:  http.StatusInternalServerError,
+}
+
+var aggregations = map[string]func(values []float64) float64{
+	"max": func(values []float64) float64 {
+		result := values[0]
+		for _, value := range values[1:] {
+			result = math.Max(result, value)
+		}
+		return result
+	},
+	"min": func(values []float64) float64 {
+		result := values[0]
+		for _, value := range values[1:] {
+			result = math.Min(result, value)
+		}
+		return result
+	},
+	"sum": sumValues,
+	"avg": func(values []float64) float64 {
+		return sumValues(values) / float64(len(values))
+	},
+}
+
+func sumValues(values []float64) float64 {
+	var result float64
+	for _, value := range values {
+		result += value
+	}
+	return result
+}
+
+//checkThreshold is a Nagios plugin threshold range, a value outside the range (or inside when inverted with @) alerts
+type checkThreshold struct {
+	raw    string
+	start  float64
+	end    float64
+	inside bool
+}
+
+//parseCheckThreshold accepts the Nagios range formats 10, 10:, ~:10, 10:20 and @10:20
+func parseCheckThreshold(raw string) (*checkThreshold, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	threshold := &checkThreshold{raw: raw, end: math.Inf(1)}
+
+	rangeString := raw
+	if strings.HasPrefix(rangeString, "@") {
+		threshold.inside = true
+		rangeString = rangeString[1:]
+	}
+
+	var err error
+	parts := strings.SplitN(rangeString, ":", 2)
+	if len(parts) == 1 {
+		threshold.end, err = strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid threshold %s", raw)
+		}
+		return threshold, nil
+	}
+
+	switch parts[0] {
+	case "~":
+		threshold.start = math.Inf(-1)
+	case "":
+	default:
+		threshold.start, err = strconv.ParseFloat(parts[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid threshold %s", raw)
+		}
+	}
+
+	if parts[1] != "" {
+		threshold.end, err = strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid threshold %s", raw)
+		}
+	}
+
+	if threshold.start > threshold.end {
+		return nil, fmt.Errorf("Invalid threshold %s, start is greater than end", raw)
+	}
+
+	return threshold, nil
+}
+
+func (threshold *checkThreshold) alerts(value float64) bool {
+	if threshold == nil {
+		return false
+	}
+
+	outside := value < threshold.start || value > threshold.end
+	return outside != threshold.inside
+}
+
+func (threshold *checkThreshold) String() string {
+	if threshold == nil {
+		return ""
+	}
+	return threshold.raw
+}
+
+type checkRequest struct {
+	origin      string
+	job         string
+	metric      string
+	warning     *checkThreshold
+	critical    *checkThreshold
+	aggregation string
+}
+
+type checkResult struct {
+	state    int
+	message  string
+	perfdata []string
+}
+
+func parseCheckRequest(r *http.Request) (*checkRequest, error) {
+	query := r.URL.Query()
+	request := &checkRequest{
+		origin:      query.Get("origin"),
+		job:         query.Get("job"),
+		metric:      query.Get("metric"),
+		aggregation: query.Get("agg"),
+	}
+
+	if request.origin == "" || request.metric == "" {
+		return nil, fmt.Errorf("origin and metric parameters are required")
+	}
+
+	if request.aggregation != "" && aggregations[request.aggregation] == nil {
+		return nil, fmt.Errorf("Unknown aggregation %s, expected one of max, min, avg or sum", request.aggregation)
+	}
+
+	var err error
+	request.warning, err = parseCheckThreshold(query.Get("warn"))
+	if err != nil {
+		return nil, err
+	}
+
+	request.critical, err = parseCheckThreshold(query.Get("crit"))
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+//evaluateCheck reports the worst state across instances unless the values are aggregated into one
+func evaluateCheck(request *checkRequest, resources []Resource) checkResult {
+	var labels []string
+	values := make(map[string]float64)
+	for _, resource := range resources {
+		if request.job != "" && resource.Job != request.job {
+			continue
+		}
+
+		value, ok := resource.ValueMetrics[request.metric]
+		if !ok {
+			value, ok = resource.CounterMetrics[request.metric]
+		}
+
+		if ok {
+			label := fmt.Sprintf("%s/%s", resource.Job, resource.Index)
+			labels = append(labels, label)
+			values[label] = value
+		}
+	}
+
+	if len(labels) == 0 {
+		return checkResult{
+			state:   checkUnknown,
+			message: fmt.Sprintf("No %s instances report metric %s", request.origin, request.metric),
+		}
+	}
+	sort.Strings(labels)
+
+	if request.aggregation != "" {
+		list := make([]float64, 0, len(labels))
+		for _, label := range labels {
+			list = append(list, values[label])
+		}
+
+		label := fmt.Sprintf("%s_%s", request.aggregation, request.metric)
+		value := aggregations[request.aggregation](list)
+		return checkResult{
+			state:    request.state(value),
+			message:  fmt.Sprintf("%s %s of %d instances is %s", request.metric, request.aggregation, len(labels), formatCheckValue(value)),
+			perfdata: []string{request.perfdata(label, value)},
+		}
+	}
+
+	result := checkResult{state: checkOK}
+	var failing []string
+	for _, label := range labels {
+		value := values[label]
+		state := request.state(value)
+		if state > result.state {
+			result.state = state
+		}
+
+		if state != checkOK {
+			failing = append(failing, fmt.Sprintf("%s is %s", label, formatCheckValue(value)))
+		}
+		result.perfdata = append(result.perfdata, request.perfdata(label, value))
+	}
+
+	if len(failing) == 0 {
+		result.message = fmt.Sprintf("%s of %d instances within thresholds", request.metric, len(labels))
+	} else {
+		result.message = fmt.Sprintf("%s on %s", request.metric, strings.Join(failing, ", "))
+	}
+
+	return result
+}
+
+func (request *checkRequest) state(value float64) int {
+	switch {
+	case request.critical.alerts(value):
+		return checkCritical
+	case request.warning.alerts(value):
+		return checkWarning
+	default:
+		return checkOK
+	}
+}
+
+func (request *checkRequest) perfdata(label string, value float64) string {
+	return fmt.Sprintf("'%s'=%s;%s;%s", label, formatCheckValue(value), request.warning, request.critical)
+}
+
+//formatCheckValue avoids exponent notation which perfdata parsers do not accept
+func formatCheckValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+//String formats the result as Nagios plugin output
+func (result checkResult) String() string {
+	output := fmt.Sprintf("%s - %s", checkStateNames[result.state], result.message)
+	if len(result.perfdata) > 0 {
+		output = fmt.Sprintf("%s | %s", output, strings.Join(result.perfdata, " "))
+	}
+	return output + "\n"
+}
+
+func (webserver *WebServer) checkHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /check request")
+
+	client, ok := webserver.authorizeCheckRequest(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := parseCheckRequest(r)
+	if err == nil && !webserver.authorizeOrigin(w, client, request.origin) {
+		return
+	}
+
+	var result checkResult
+	if err != nil {
+		result = checkResult{state: checkUnknown, message: err.Error()}
+	} else {
+		webserver.mutext.Lock()
+		var resources []Resource
+		for _, resource := range webserver.cache[request.origin] {
+			resources = append(resources, resource)
+		}
+		result = evaluateCheck(request, resources)
+		webserver.mutext.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set(checkStateHeader, checkStateNames[result.state])
+	w.WriteHeader(checkStatusCodes[result.state])
+	_, err = io.WriteString(w, result.String())
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /check call: %s", err.Error())
+	}
+}
+
+//authorizeCheckRequest accepts the configured API key as an alternative to a token, as monitoring systems can only call a URL.
+//The API key may check every origin and is only read from a header, so it does not end up in access logs
+func (webserver *WebServer) authorizeCheckRequest(w http.ResponseWriter, r *http.Request) (*apiClient, bool) {
+	apiKey := r.Header.Get(apiKeyHeader)
+
+	if r.Method == "GET" && apiKey != "" && webserver.config.CheckAPIKey != "" {
+		if !webserver.limitRequest(w, r, ipRateLimitKey(r)) {
+			return nil, false
+		}
+
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(webserver.config.CheckAPIKey)) == 1 {
+			return nil, true
+		}
+
+		webserver.logger.Debug("Invalid api key supplied")
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Invalid api key supplied")
+		return nil, false
+	}
+
+	return webserver.authorizeClient(w, r)
+}
@@ -0,0 +1,153 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	cases := []struct {
+		threshold string
+		value     float64
+		alerts    bool
+	}{
+		{"10", 5, false},
+		{"10", 11, true},
+		{"10", -1, true},
+		{"10:", 5, true},
+		{"10:", 15, false},
+		{"~:10", -100, false},
+		{"~:10", 11, true},
+		{"10:20", 15, false},
+		{"10:20", 25, true},
+		{"@10:20", 15, true},
+		{"@10:20", 25, false},
+	}
+
+	for _, c := range cases {
+		threshold, err := parseCheckThreshold(c.threshold)
+		if err != nil {
+			t.Fatalf("Error parsing threshold %s: %s", c.threshold, err.Error())
+		}
+
+		t.Logf("Checking value %v against threshold %s... (expected value: %v)", c.value, c.threshold, c.alerts)
+		if threshold.alerts(c.value) != c.alerts {
+			t.Errorf("Expected alert %v for value %v and threshold %s, but received %v", c.alerts, c.value, c.threshold, !c.alerts)
+		}
+	}
+
+	for _, invalid := range []string{"abc", "20:10", "1:x"} {
+		t.Logf("Checking invalid threshold %s... (expecting error)", invalid)
+		if _, err := parseCheckThreshold(invalid); err == nil {
+			t.Errorf("Expected error parsing threshold %s, but received none", invalid)
+		}
+	}
+}
+
+func TestCheckPerInstance(t *testing.T) {
+	request := createCheckRequest(t, "/check?origin=rep&metric=CapacityRemainingMemory&warn=4096:&crit=1024:")
+	result := evaluateCheck(request, createCheckResources())
+
+	expected := "CRITICAL - CapacityRemainingMemory on cell/1 is 2048, cell/2 is 512 | " +
+		"'cell/0'=8192;4096:;1024: 'cell/1'=2048;4096:;1024: 'cell/2'=512;4096:;1024:\n"
+
+	t.Logf("Checking plugin output... (expected value: %s)", expected)
+	if result.String() != expected {
+		t.Errorf("Expected output %s, but received %s", expected, result.String())
+	}
+}
+
+func TestCheckAggregated(t *testing.T) {
+	request := createCheckRequest(t, "/check?origin=rep&metric=CapacityRemainingMemory&warn=4096:&agg=max")
+	result := evaluateCheck(request, createCheckResources())
+
+	expected := "OK - CapacityRemainingMemory max of 3 instances is 8192 | 'max_CapacityRemainingMemory'=8192;4096:;\n"
+
+	t.Logf("Checking plugin output... (expected value: %s)", expected)
+	if result.String() != expected {
+		t.Errorf("Expected output %s, but received %s", expected, result.String())
+	}
+}
+
+func TestCheckUnknown(t *testing.T) {
+	request := createCheckRequest(t, "/check?origin=rep&metric=Missing&crit=10")
+	result := evaluateCheck(request, createCheckResources())
+
+	t.Logf("Checking state of missing metric... (expected value: %d)", checkUnknown)
+	if result.state != checkUnknown {
+		t.Errorf("Expected state %d, but received %d", checkUnknown, result.state)
+	}
+
+	for _, query := range []string{"/check?metric=memory", "/check?origin=rep&metric=memory&agg=median", "/check?origin=rep&metric=memory&warn=x"} {
+		r, _ := http.NewRequest("GET", query, nil)
+
+		t.Logf("Checking invalid request %s... (expecting error)", query)
+		if _, err := parseCheckRequest(r); err == nil {
+			t.Errorf("Expected error parsing %s, but received none", query)
+		}
+	}
+}
+
+func TestCheckWarningStatus(t *testing.T) {
+	server := createAuthWebServer()
+	server.config.CheckAPIKey = "check-key"
+	server.cache["rep"] = make(map[string]Resource)
+	for _, resource := range createCheckResources() {
+		server.cache["rep"][resource.Index] = resource
+	}
+
+	request := httptest.NewRequest("GET", "/check?origin=rep&metric=CapacityRemainingMemory&warn=1024:", nil)
+	request.Header.Add(apiKeyHeader, "check-key")
+	recorder := httptest.NewRecorder()
+	server.checkHandler(recorder, request)
+
+	t.Logf("Checking status code of WARNING state... (expected value: %d)", http.StatusOK)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, but received %d", http.StatusOK, recorder.Code)
+	}
+
+	t.Logf("Checking state header... (expected value: %s)", checkStateNames[checkWarning])
+	if state := recorder.Header().Get(checkStateHeader); state != checkStateNames[checkWarning] {
+		t.Errorf("Expected state header %s, but received %s", checkStateNames[checkWarning], state)
+	}
+
+	request = httptest.NewRequest("GET", "/check?origin=rep&metric=CapacityRemainingMemory&api_key=check-key", nil)
+	recorder = httptest.NewRecorder()
+	server.checkHandler(recorder, request)
+
+	t.Logf("Checking api key in query string... (expected value: %d)", http.StatusUnauthorized)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d, but received %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
+
+func createCheckRequest(t *testing.T, query string) *checkRequest {
+	r, _ := http.NewRequest("GET", query, nil)
+	request, err := parseCheckRequest(r)
+	if err != nil {
+		t.Fatalf("Error parsing check request %s: %s", query, err.Error())
+	}
+
+	return request
+}
+
+func createCheckResources() []Resource {
+	var resources []Resource
+	for index, value := range []float64{8192, 2048, 512} {
+		resources = append(resources, Resource{
+			Deployment:     "cf",
+			Job:            "cell",
+			Index:          strconv.Itoa(index),
+			IP:             "10.0.0.1",
+			ValueMetrics:   map[string]float64{"CapacityRemainingMemory": value},
+			CounterMetrics: make(map[string]float64),
+		})
+	}
+
+	return resources
+}
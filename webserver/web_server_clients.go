@@ -0,0 +1,165 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+const allEndpoints = "*"
+
+//apiClient is a configured API consumer, only the SHA-256 hash of its secret is known. Clients without a secret log
+//in with a client certificate, UAA or a bearer token instead
+type apiClient struct {
+	secretHash []byte
+	endpoints  map[string]bool
+	origins    map[string]bool
+}
+
+//createAPIClients expands the endpoint groups of every client into the endpoints and origins it may read
+func createAPIClients(config *nozzleconfiguration.NozzleConfiguration, routes []route) (map[string]*apiClient, error) {
+	clients := make(map[string]*apiClient, len(config.APIClients))
+	for _, clientConfig := range config.APIClients {
+		if clientConfig.Name == "" {
+			return nil, fmt.Errorf("API client without a name")
+		}
+
+		if _, ok := clients[clientConfig.Name]; ok {
+			return nil, fmt.Errorf("API client %s is configured twice", clientConfig.Name)
+		}
+
+		var secretHash []byte
+		if clientConfig.SecretSHA256 != "" {
+			var err error
+			secretHash, err = hex.DecodeString(clientConfig.SecretSHA256)
+			if err != nil || len(secretHash) != sha256.Size {
+				return nil, fmt.Errorf("SecretSHA256 of API client %s is not a hex encoded SHA-256 hash", clientConfig.Name)
+			}
+		}
+
+		client := &apiClient{
+			secretHash: secretHash,
+			endpoints:  make(map[string]bool),
+			origins:    make(map[string]bool),
+		}
+
+		for _, endpoint := range clientConfig.Endpoints {
+			entries := []string{endpoint}
+			if group, ok := config.EndpointGroups[endpoint]; ok {
+				entries = group
+			}
+
+			for _, entry := range entries {
+				if err := client.allow(entry, routes); err != nil {
+					return nil, fmt.Errorf("API client %s %s", clientConfig.Name, err)
+				}
+			}
+		}
+
+		clients[clientConfig.Name] = client
+	}
+
+	return clients, nil
+}
+
+//allow grants an endpoint or an origin, the endpoint serving the resources of an origin grants that origin and the
+//other way around. Entries that are neither an endpoint nor start with a slash name an origin
+func (client *apiClient) allow(entry string, routes []route) error {
+	if entry == allEndpoints {
+		client.endpoints[allEndpoints] = true
+		client.origins[allEndpoints] = true
+		return nil
+	}
+
+	endpoint := normalizeEndpoint(entry)
+	for _, route := range routes {
+		if route.pattern == endpoint {
+			client.endpoints[endpoint] = true
+			if route.origin != "" {
+				client.origins[route.origin] = true
+			}
+			return nil
+		}
+	}
+
+	if strings.HasPrefix(entry, "/") {
+		return fmt.Errorf("allows unknown endpoint %s", entry)
+	}
+
+	client.origins[entry] = true
+	for _, route := range routes {
+		if route.origin == entry {
+			client.endpoints[route.pattern] = true
+		}
+	}
+	return nil
+}
+
+func normalizeEndpoint(endpoint string) string {
+	if endpoint == allEndpoints || strings.HasPrefix(endpoint, "/") {
+		return endpoint
+	}
+	return "/" + endpoint
+}
+
+func (client *apiClient) validSecret(secret string) bool {
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(hash[:], client.secretHash) == 1
+}
+
+func (client *apiClient) allowed(path string) bool {
+	return client.endpoints[allEndpoints] || client.endpoints[path]
+}
+
+//allowedOrigin may be called on a nil client, which may read every origin
+func (client *apiClient) allowedOrigin(origin string) bool {
+	return client == nil || client.origins[allEndpoints] || client.origins[origin]
+}
+
+//authorizeEndpoint answers the request with 403 when API clients are configured and user is not one of them or may
+//not read the requested path. The returned client is nil when every endpoint and origin may be read, as no API
+//clients are configured or the listener does not require authentication
+func (webserver *WebServer) authorizeEndpoint(w http.ResponseWriter, r *http.Request, user string) (*apiClient, bool) {
+	if len(webserver.apiClients) == 0 {
+		return nil, true
+	}
+
+	if listener := requestListener(r); listener != nil && !listener.requireAuthentication {
+		return nil, true
+	}
+
+	client, ok := webserver.apiClients[user]
+	if ok && client.allowed(r.URL.Path) {
+		return client, true
+	}
+
+	if ok {
+		webserver.logger.Warnf("Denied API client %s access to %s", user, r.URL.Path)
+	} else {
+		webserver.logger.Warnf("Denied %s access to %s as it is not a configured API client", user, r.URL.Path)
+	}
+	w.WriteHeader(http.StatusForbidden)
+	io.WriteString(w, fmt.Sprintf("Access to %s denied", r.URL.Path))
+	return nil, false
+}
+
+//authorizeOrigin answers the request with 403 when client may not read origin
+func (webserver *WebServer) authorizeOrigin(w http.ResponseWriter, client *apiClient, origin string) bool {
+	if client.allowedOrigin(origin) {
+		return true
+	}
+
+	webserver.logger.Warnf("Denied access to origin %s", origin)
+	w.WriteHeader(http.StatusForbidden)
+	io.WriteString(w, fmt.Sprintf("Access to origin %s denied", origin))
+	return false
+}
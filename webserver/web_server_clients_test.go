@@ -0,0 +1,154 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+func TestAPIClients(t *testing.T) {
+	secretHash := sha256.Sum256([]byte("app-metrics-secret"))
+	server := createLibraryWebServer(t, 0)
+	config := &nozzleconfiguration.NozzleConfiguration{
+		EndpointGroups: map[string][]string{
+			"routing": {"/gorouters", "traffic_controllers"},
+		},
+		APIClients: []nozzleconfiguration.APIClientConfiguration{
+			{Name: "app-metrics", SecretSHA256: hex.EncodeToString(secretHash[:]), Endpoints: []string{"routing", "kpis", "rep"}},
+			{Name: "user", Endpoints: []string{"*"}},
+		},
+	}
+
+	clients, err := createAPIClients(config, server.routes)
+	if err != nil {
+		t.Fatalf("Expected API clients, but received error %s", err.Error())
+	}
+	server.apiClients = clients
+
+	t.Log("Checking API client secret... (expected value: valid only for the configured secret)")
+	if valid, _ := server.validateCredentials("app-metrics", "app-metrics-secret"); !valid {
+		t.Errorf("Expected valid secret, but received invalid")
+	}
+	if valid, _ := server.validateCredentials("app-metrics", "wrong-secret"); valid {
+		t.Errorf("Expected invalid secret, but received valid")
+	}
+
+	t.Log("Checking API client without a secret logs in like other users... (expected value: valid)")
+	if valid, _ := server.validateCredentials("user", "password"); !valid {
+		t.Errorf("Expected valid login of the UAA user, but received invalid")
+	}
+
+	expectedStatuses := map[string]int{
+		"/gorouters":           http.StatusOK,
+		"/traffic_controllers": http.StatusOK,
+		"/kpis":                http.StatusOK,
+		"/reps":                http.StatusOK,
+		"/bbs":                 http.StatusForbidden,
+		"/admin/tokens":        http.StatusForbidden,
+	}
+
+	for path, expectedStatus := range expectedStatuses {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "https://localhost"+path, nil)
+
+		t.Logf("Checking API client access to %s... (expecting status code: %v)", path, expectedStatus)
+		if _, ok := server.authorizeEndpoint(recorder, request, "app-metrics"); ok {
+			recorder.WriteHeader(http.StatusOK)
+		}
+
+		if recorder.Code != expectedStatus {
+			t.Errorf("Expecting status code %v, but received %v", expectedStatus, recorder.Code)
+		}
+	}
+
+	client := clients["app-metrics"]
+	expectedOrigins := map[string]bool{
+		goRouterOrigin:          true,
+		trafficControllerOrigin: true,
+		repOrigin:               true,
+		bbsOrigin:               false,
+		uaaOrigin:               false,
+	}
+
+	for origin, expected := range expectedOrigins {
+		t.Logf("Checking API client access to origin %s... (expected value: %v)", origin, expected)
+		if client.allowedOrigin(origin) != expected {
+			t.Errorf("Expected access to origin %s to be %v, but received %v", origin, expected, !expected)
+		}
+	}
+
+	t.Log("Checking users that are not API clients are denied...")
+	for _, user := range []string{"uaa-user", "certificate-user", ""} {
+		request, _ := http.NewRequest("GET", "https://localhost/bbs", nil)
+		if _, ok := server.authorizeEndpoint(httptest.NewRecorder(), request, user); ok {
+			t.Errorf("Expected access of %q to be denied, but was allowed", user)
+		}
+	}
+}
+
+func TestAPIClientOrigins(t *testing.T) {
+	server := createLibraryWebServer(t, 0)
+	clients, err := createAPIClients(&nozzleconfiguration.NozzleConfiguration{
+		APIClients: []nozzleconfiguration.APIClientConfiguration{
+			{Name: "user", Endpoints: []string{"/check", "/kpis", "/capacity", "gorouter"}},
+		},
+	}, server.routes)
+	if err != nil {
+		t.Fatalf("Expected API clients, but received error %s", err.Error())
+	}
+	server.apiClients = clients
+
+	cacheEnvelope(goRouterOrigin, server)
+	cacheEnvelope(bbsOrigin, server)
+	_, token, _ := server.tokens.Create("user")
+
+	expectedStatuses := map[string]int{
+		"/check?origin=" + goRouterOrigin + "&metric=metric": http.StatusOK,
+		"/check?origin=" + bbsOrigin + "&metric=metric":      http.StatusForbidden,
+		"/capacity?memory_mb=1024":                           http.StatusForbidden,
+	}
+
+	for path, expectedStatus := range expectedStatuses {
+		recorder := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "https://localhost"+path, nil)
+		request.Header.Set(headerTokenKey, token)
+
+		t.Logf("Checking access to %s... (expecting status code: %v)", path, expectedStatus)
+		server.ServeHTTP(recorder, request)
+		if recorder.Code != expectedStatus {
+			t.Errorf("Expecting status code %v, but received %v", expectedStatus, recorder.Code)
+		}
+	}
+
+	server.mutext.Lock()
+	kpis := server.calculateKPIs(clients["user"])
+	server.mutext.Unlock()
+
+	t.Log("Checking only KPIs of allowed origins are returned... (expected value: 2 gorouter KPIs)")
+	if len(kpis) != 2 || kpis[0].Name != "GorouterBadGatewayRate" || kpis[1].Name != "GorouterLatency" {
+		t.Errorf("Expected the 2 gorouter KPIs, but received %+v", kpis)
+	}
+}
+
+func TestInvalidAPIClients(t *testing.T) {
+	invalidClients := map[string][]nozzleconfiguration.APIClientConfiguration{
+		"missing name":   {{SecretSHA256: hex.EncodeToString(make([]byte, sha256.Size))}},
+		"invalid hash":   {{Name: "client", SecretSHA256: "secret"}},
+		"unknown path":   {{Name: "client", Endpoints: []string{"/unknown"}}},
+		"duplicate name": {{Name: "client", SecretSHA256: hex.EncodeToString(make([]byte, sha256.Size))}, {Name: "client", SecretSHA256: hex.EncodeToString(make([]byte, sha256.Size))}},
+	}
+
+	for name, clients := range invalidClients {
+		t.Logf("Checking API clients with %s... (expected error)", name)
+		if _, err := createAPIClients(&nozzleconfiguration.NozzleConfiguration{APIClients: clients}, nil); err == nil {
+			t.Errorf("Expected error for API clients with %s, but received none", name)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+)
+
+const defaultReadinessWindowSeconds = 60
+
+//FirehoseState is the state of the firehose subscription reported by /ready
+type FirehoseState string
+
+//Firehose states set by the nozzle
+const (
+	FirehoseConnecting   FirehoseState = "connecting"
+	FirehoseConnected    FirehoseState = "connected"
+	FirehoseDisconnected FirehoseState = "disconnected"
+)
+
+//publicRoutes are exposed on every listener and answered without authentication
+var publicRoutes = []string{"/health", "/ready"}
+
+//Health is the response of /health
+type Health struct {
+	Status        string
+	StartedAt     time.Time
+	UptimeSeconds float64
+}
+
+//Readiness is the response of /ready
+type Readiness struct {
+	Ready                    bool
+	FirehoseState            FirehoseState
+	UAATokenValid            bool
+	UAATokenExpiresAt        *time.Time `json:",omitempty"`
+	LastEnvelopeAt           *time.Time `json:",omitempty"`
+	SecondsSinceLastEnvelope *float64   `json:",omitempty"`
+	ReadinessWindowSeconds   uint32
+	Reconnects               uint64
+	CachedResources          int
+}
+
+//health is updated by the nozzle as the firehose subscription and UAA token change
+type health struct {
+	startedAt      time.Time
+	firehoseState  FirehoseState
+	connected      bool
+	reconnects     uint64
+	uaaTokenExpiry time.Time
+}
+
+func isPublicRoute(pattern string) bool {
+	for _, publicRoute := range publicRoutes {
+		if pattern == publicRoute {
+			return true
+		}
+	}
+	return false
+}
+
+//SetFirehoseState records the state of the firehose subscription, every connect after the first counts as reconnect
+func (webserver *WebServer) SetFirehoseState(state FirehoseState) {
+	webserver.healthMutex.Lock()
+	defer webserver.healthMutex.Unlock()
+
+	if state == FirehoseConnected {
+		if webserver.health.connected {
+			webserver.health.reconnects++
+			webserver.selfStats.Increment(stats.FirehoseReconnects)
+		}
+		webserver.health.connected = true
+	}
+
+	webserver.health.firehoseState = state
+}
+
+//SetUAATokenExpiry records when the UAA token the nozzle subscribed with expires
+func (webserver *WebServer) SetUAATokenExpiry(expiry time.Time) {
+	webserver.healthMutex.Lock()
+	defer webserver.healthMutex.Unlock()
+
+	webserver.health.uaaTokenExpiry = expiry
+}
+
+//readiness must be called with the mutex held
+func (webserver *WebServer) readiness(now time.Time) Readiness {
+	webserver.healthMutex.Lock()
+	state := webserver.health
+	webserver.healthMutex.Unlock()
+
+	readiness := Readiness{
+		FirehoseState:          state.firehoseState,
+		UAATokenValid:          webserver.config.DisableAccessControl,
+		ReadinessWindowSeconds: webserver.config.ReadinessWindowSeconds,
+		Reconnects:             state.reconnects,
+	}
+
+	if !state.uaaTokenExpiry.IsZero() {
+		expiresAt := state.uaaTokenExpiry.UTC()
+		readiness.UAATokenExpiresAt = &expiresAt
+		readiness.UAATokenValid = now.Before(state.uaaTokenExpiry)
+	}
+
+	if !webserver.lastEnvelope.IsZero() {
+		lastEnvelope := webserver.lastEnvelope.UTC()
+		sinceLastEnvelope := now.Sub(webserver.lastEnvelope).Seconds()
+		readiness.LastEnvelopeAt = &lastEnvelope
+		readiness.SecondsSinceLastEnvelope = &sinceLastEnvelope
+
+		window := time.Duration(webserver.config.ReadinessWindowSeconds) * time.Second
+		readiness.Ready = now.Sub(webserver.lastEnvelope) <= window
+	}
+
+	for _, resourceMap := range webserver.cache {
+		readiness.CachedResources += len(resourceMap)
+	}
+
+	return readiness
+}
+
+//healthHandler answers as long as the process is alive, it does not require authentication
+func (webserver *WebServer) healthHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /health request")
+	if !allowProbeMethod(w, r) {
+		return
+	}
+
+	now := time.Now()
+	health := Health{
+		Status:        "UP",
+		StartedAt:     webserver.health.startedAt.UTC(),
+		UptimeSeconds: now.Sub(webserver.health.startedAt).Seconds(),
+	}
+
+	webserver.sendProbe(w, http.StatusOK, health)
+}
+
+//readyHandler answers 503 until an envelope arrived within ReadinessWindowSeconds, it does not require authentication
+func (webserver *WebServer) readyHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /ready request")
+	if !allowProbeMethod(w, r) {
+		return
+	}
+
+	webserver.mutext.Lock()
+	readiness := webserver.readiness(time.Now())
+	webserver.mutext.Unlock()
+
+	status := http.StatusOK
+	if !readiness.Ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	webserver.sendProbe(w, status, readiness)
+}
+
+func allowProbeMethod(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		io.WriteString(w, fmt.Sprintf("Unsupported http method %s", r.Method))
+		return false
+	}
+	return true
+}
+
+func (webserver *WebServer) sendProbe(w http.ResponseWriter, status int, value interface{}) {
+	messageBytes, _ := json.Marshal(value)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err := w.Write(messageBytes)
+	if err != nil {
+		webserver.logger.Errorf("Error while answering probe: %s", err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHealthProbesWithoutAuthentication(t *testing.T) {
+	server := createHealthWebServer()
+	external := &listener{name: "external", requireAuthentication: true, routes: map[string]bool{"/kpis": true}}
+
+	for _, path := range []string{"/health", "/ready"} {
+		request, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+		recorder := httptest.NewRecorder()
+		server.listenerHandler(external).ServeHTTP(recorder, request)
+
+		t.Logf("Check %s without token on listener not exposing it... (expecting it to be answered)", path)
+		if recorder.Code == http.StatusNotFound || recorder.Code == http.StatusUnauthorized {
+			t.Errorf("Expected %s to be answered without authentication, but received status code %v", path, recorder.Code)
+		}
+	}
+
+	request, _ := http.NewRequest("POST", "http://localhost/health", nil)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+
+	t.Logf("Check POST /health... (expecting status code: %v)", http.StatusMethodNotAllowed)
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusMethodNotAllowed, recorder.Code)
+	}
+}
+
+func TestReady(t *testing.T) {
+	server := createHealthWebServer()
+
+	readiness, status := requestReadiness(t, server)
+	t.Logf("Check /ready before any envelope... (expecting status code: %v)", http.StatusServiceUnavailable)
+	if status != http.StatusServiceUnavailable || readiness.Ready || readiness.LastEnvelopeAt != nil {
+		t.Errorf("Expecting status code %v without last envelope, but received %v and %+v", http.StatusServiceUnavailable, status, readiness)
+	}
+
+	server.SetFirehoseState(FirehoseConnecting)
+	server.SetFirehoseState(FirehoseConnected)
+	server.SetFirehoseState(FirehoseConnecting)
+	server.SetFirehoseState(FirehoseConnected)
+	server.SetUAATokenExpiry(time.Now().Add(time.Hour))
+	server.CacheEnvelope(createKPIValueMetric(goRouterOrigin, "0", "latency", 1))
+	server.CacheEnvelope(createKPIValueMetric(goRouterOrigin, "1", "latency", 1))
+
+	readiness, status = requestReadiness(t, server)
+	t.Logf("Check /ready after an envelope... (expecting status code: %v)", http.StatusOK)
+	if status != http.StatusOK || !readiness.Ready {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusOK, status)
+	}
+
+	t.Log("Check reported state... (expecting connected with 1 reconnect, valid token and 2 cached resources)")
+	if readiness.FirehoseState != FirehoseConnected || readiness.Reconnects != 1 || !readiness.UAATokenValid || readiness.CachedResources != 2 {
+		t.Errorf("Expected connected with 1 reconnect, valid token and 2 cached resources, but received %+v", readiness)
+	}
+
+	server.lastEnvelope = time.Now().Add(-2 * time.Minute)
+	server.SetUAATokenExpiry(time.Now().Add(-time.Minute))
+
+	readiness, status = requestReadiness(t, server)
+	t.Logf("Check /ready after the readiness window passed... (expecting status code: %v)", http.StatusServiceUnavailable)
+	if status != http.StatusServiceUnavailable || readiness.UAATokenValid {
+		t.Errorf("Expecting status code %v with expired token, but received %v and %+v", http.StatusServiceUnavailable, status, readiness)
+	}
+}
+
+func createHealthWebServer() *WebServer {
+	server := createAuthWebServer()
+	server.config.ReadinessWindowSeconds = 60
+	server.health = health{startedAt: time.Now(), firehoseState: FirehoseDisconnected}
+	server.handleFunc("/kpis", server.kpisHandler)
+	server.handleFunc("/health", server.healthHandler)
+	server.handleFunc("/ready", server.readyHandler)
+	return server
+}
+
+func requestReadiness(t *testing.T, server *WebServer) (Readiness, int) {
+	request, _ := http.NewRequest("GET", "http://localhost/ready", nil)
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+
+	var readiness Readiness
+	err := json.Unmarshal(recorder.Body.Bytes(), &readiness)
+	if err != nil {
+		t.Fatalf("Error decoding /ready response: %s", err.Error())
+	}
+
+	return readiness, recorder.Code
+}
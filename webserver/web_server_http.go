@@ -0,0 +1,106 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+const (
+	defaultMinTLSVersion            = "1.2"
+	defaultReadTimeoutSeconds       = 30
+	defaultReadHeaderTimeoutSeconds = 10
+	defaultWriteTimeoutSeconds      = 60
+	defaultIdleTimeoutSeconds       = 120
+	defaultMaxHeaderBytes           = 64 * 1024
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func setHTTPServerDefaults(config *nozzleconfiguration.HTTPServerConfiguration) {
+	if config.MinTLSVersion == "" {
+		config.MinTLSVersion = defaultMinTLSVersion
+	}
+
+	if config.ReadTimeoutSeconds == 0 {
+		config.ReadTimeoutSeconds = defaultReadTimeoutSeconds
+	}
+
+	if config.ReadHeaderTimeoutSeconds == 0 {
+		config.ReadHeaderTimeoutSeconds = defaultReadHeaderTimeoutSeconds
+	}
+
+	if config.WriteTimeoutSeconds == 0 {
+		config.WriteTimeoutSeconds = defaultWriteTimeoutSeconds
+	}
+
+	if config.IdleTimeoutSeconds == 0 {
+		config.IdleTimeoutSeconds = defaultIdleTimeoutSeconds
+	}
+
+	if config.MaxHeaderBytes == 0 {
+		config.MaxHeaderBytes = defaultMaxHeaderBytes
+	}
+}
+
+//applyTLSPolicy sets the minimum TLS version and cipher suites, only cipher suites Go considers secure are accepted
+func applyTLSPolicy(tlsConfig *tls.Config, config *nozzleconfiguration.HTTPServerConfiguration) error {
+	version, ok := tlsVersions[config.MinTLSVersion]
+	if !ok {
+		return fmt.Errorf("Unsupported minimum TLS version %s, expected one of 1.0, 1.1, 1.2 or 1.3", config.MinTLSVersion)
+	}
+	tlsConfig.MinVersion = version
+
+	if len(config.CipherSuites) == 0 {
+		return nil
+	}
+
+	secureSuites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		secureSuites[suite.Name] = suite.ID
+	}
+
+	tlsConfig.CipherSuites = nil
+	for _, name := range config.CipherSuites {
+		id, ok := secureSuites[name]
+		if !ok {
+			return fmt.Errorf("Unsupported or insecure cipher suite %s", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+
+	return nil
+}
+
+//listenAddress joins bind address and port, an empty bind address listens on all interfaces
+func listenAddress(bindAddress string, port uint32) string {
+	return net.JoinHostPort(bindAddress, strconv.FormatUint(uint64(port), 10))
+}
+
+//newHTTPServer creates the http.Server with the configured limits and TLS policy
+func (webserver *WebServer) newHTTPServer(address string, handler http.Handler) *http.Server {
+	config := &webserver.config.HTTPServer
+	return &http.Server{
+		Addr:              address,
+		Handler:           handler,
+		TLSConfig:         webserver.tlsConfig,
+		ReadTimeout:       time.Duration(config.ReadTimeoutSeconds) * time.Second,
+		ReadHeaderTimeout: time.Duration(config.ReadHeaderTimeoutSeconds) * time.Second,
+		WriteTimeout:      time.Duration(config.WriteTimeoutSeconds) * time.Second,
+		IdleTimeout:       time.Duration(config.IdleTimeoutSeconds) * time.Second,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+}
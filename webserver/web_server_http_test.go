@@ -0,0 +1,75 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/tls"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+func TestTLSPolicy(t *testing.T) {
+	config := &nozzleconfiguration.HTTPServerConfiguration{}
+	setHTTPServerDefaults(config)
+
+	tlsConfig := &tls.Config{}
+	t.Log("Check if TLS 1.2 is the default minimum version... (expecting TLS 1.2)")
+	if err := applyTLSPolicy(tlsConfig, config); err != nil || tlsConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("Expected minimum version %x, but received %x and error %v", tls.VersionTLS12, tlsConfig.MinVersion, err)
+	}
+
+	config.MinTLSVersion = "1.3"
+	config.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}
+	t.Log("Check if configured version and cipher suites are applied... (expecting TLS 1.3 and 2 cipher suites)")
+	if err := applyTLSPolicy(tlsConfig, config); err != nil || tlsConfig.MinVersion != tls.VersionTLS13 || len(tlsConfig.CipherSuites) != 2 ||
+		tlsConfig.CipherSuites[0] != tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("Expected TLS 1.3 with 2 cipher suites, but received %x, %v and error %v", tlsConfig.MinVersion, tlsConfig.CipherSuites, err)
+	}
+
+	invalid := map[string]nozzleconfiguration.HTTPServerConfiguration{
+		"unknown version":       {MinTLSVersion: "1.4"},
+		"insecure cipher suite": {MinTLSVersion: "1.2", CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		"unknown cipher suite":  {MinTLSVersion: "1.2", CipherSuites: []string{"TLS_NOT_A_SUITE"}},
+	}
+
+	for name, config := range invalid {
+		t.Logf("Check if %s is rejected... (expected error)", name)
+		if err := applyTLSPolicy(&tls.Config{}, &config); err == nil {
+			t.Errorf("Expected error for %s, but received none", name)
+		}
+	}
+}
+
+func TestHTTPServerLimits(t *testing.T) {
+	server := createKPIWebServer()
+	server.config = &nozzleconfiguration.NozzleConfiguration{
+		WebServerPort:        8081,
+		WebServerBindAddress: "127.0.0.1",
+		HTTPServer: nozzleconfiguration.HTTPServerConfiguration{
+			WriteTimeoutSeconds: 5,
+		},
+	}
+	setHTTPServerDefaults(&server.config.HTTPServer)
+	httpServer := server.newHTTPServer(listenAddress(server.config.WebServerBindAddress, server.config.WebServerPort), nil)
+
+	t.Log("Check if server binds to the configured address... (expecting 127.0.0.1:8081)")
+	if httpServer.Addr != "127.0.0.1:8081" {
+		t.Errorf("Expected address 127.0.0.1:8081, but received %s", httpServer.Addr)
+	}
+
+	t.Log("Check if configured and default limits are applied... (expecting write timeout 5s, read header timeout 10s)")
+	if httpServer.WriteTimeout != 5*time.Second || httpServer.ReadHeaderTimeout != defaultReadHeaderTimeoutSeconds*time.Second ||
+		httpServer.ReadTimeout != defaultReadTimeoutSeconds*time.Second || httpServer.IdleTimeout != defaultIdleTimeoutSeconds*time.Second ||
+		httpServer.MaxHeaderBytes != defaultMaxHeaderBytes {
+		t.Errorf("Expected configured limits, but received read %s, read header %s, write %s, idle %s and %d header bytes",
+			httpServer.ReadTimeout, httpServer.ReadHeaderTimeout, httpServer.WriteTimeout, httpServer.IdleTimeout, httpServer.MaxHeaderBytes)
+	}
+
+	t.Log("Check if empty bind address listens on all interfaces... (expecting :8081)")
+	if address := listenAddress("", 8081); address != ":8081" {
+		t.Errorf("Expected address :8081, but received %s", address)
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+const (
+	uaaOrigin = "uaa"
+
+	//Counter rates are calculated over at least this window to smooth out emission jitter
+	minRateWindow = time.Minute
+)
+
+//KPI is a Cloud Foundry health indicator derived from cached metrics
+type KPI struct {
+	Name      string
+	Unit      string
+	Value     *float64
+	Instances int
+	Missing   []string `json:",omitempty"`
+}
+
+type kpiDefinition struct {
+	name        string
+	origin      string
+	metric      string
+	unit        string
+	aggregation string
+	rate        bool
+}
+
+//kpiDefinitions follow the key performance indicators recommended for monitoring Cloud Foundry
+var kpiDefinitions = []kpiDefinition{
+	{"DiegoCellMemoryRemainingMin", repOrigin, "CapacityRemainingMemory", "MiB", "min", false},
+	{"DiegoCellMemoryRemainingTotal", repOrigin, "CapacityRemainingMemory", "MiB", "sum", false},
+	{"DiegoCellDiskRemainingMin", repOrigin, "CapacityRemainingDisk", "MiB", "min", false},
+	{"DiegoCellDiskRemainingTotal", repOrigin, "CapacityRemainingDisk", "MiB", "sum", false},
+	{"BBSLRPConvergenceDuration", bbsOrigin, "ConvergenceLRPDuration", "ns", "max", false},
+	{"BBSLRPsMissing", bbsOrigin, "LRPsMissing", "count", "max", false},
+	{"GorouterBadGatewayRate", goRouterOrigin, "bad_gateways", "per second", "sum", true},
+	{"GorouterLatency", goRouterOrigin, "latency", "ms", "avg", false},
+	{"DopplerDroppedMessageRate", dopplerServerOrigin, "TruncatingBuffer.totalDroppedMessages", "per second", "sum", true},
+	{"UAARequestRate", uaaOrigin, "requests.global.completed.count", "per second", "sum", true},
+}
+
+//rateCounters is the set of counters whose rates have to be tracked as envelopes arrive
+var rateCounters = make(map[string]bool)
+
+func init() {
+	for _, definition := range kpiDefinitions {
+		if definition.rate {
+			rateCounters[createMetricName(definition.origin, definition.metric)] = true
+		}
+	}
+}
+
+type counterSample struct {
+	total     float64
+	timestamp int64
+}
+
+//counterRate calculates the rate of one counter of one instance over a window of one to two minRateWindows
+type counterRate struct {
+	previous counterSample
+	next     counterSample //Becomes previous once it is a full window old
+	latest   counterSample
+}
+
+func newCounterRate(sample counterSample) *counterRate {
+	return &counterRate{previous: sample, next: sample, latest: sample}
+}
+
+func (rate *counterRate) add(sample counterSample) {
+	switch {
+	case sample.total < rate.latest.total:
+		//Counter was reset by a restart of the emitting process
+		rate.previous = sample
+		rate.next = sample
+	case time.Duration(sample.timestamp-rate.next.timestamp) >= minRateWindow:
+		rate.previous = rate.next
+		rate.next = sample
+	}
+
+	rate.latest = sample
+}
+
+func (rate *counterRate) perSecond() (float64, bool) {
+	elapsed := time.Duration(rate.latest.timestamp - rate.previous.timestamp)
+	if elapsed <= 0 {
+		return 0, false
+	}
+
+	return (rate.latest.total - rate.previous.total) / elapsed.Seconds(), true
+}
+
+func createMetricName(origin string, metric string) string {
+	return fmt.Sprintf("%s.%s", origin, metric)
+}
+
+func createRateKey(origin string, resourceKey string, metric string) string {
+	return fmt.Sprintf("%s | %s | %s", origin, resourceKey, metric)
+}
+
+//trackCounterRate must be called with the mutex held
+func (webserver *WebServer) trackCounterRate(envelope *events.Envelope) {
+	if envelope.GetEventType() != events.Envelope_CounterEvent {
+		return
+	}
+
+	counterEvent := envelope.GetCounterEvent()
+	if !rateCounters[createMetricName(envelope.GetOrigin(), counterEvent.GetName())] {
+		return
+	}
+
+	sample := counterSample{
+		total:     float64(counterEvent.GetTotal()),
+		timestamp: envelope.GetTimestamp(),
+	}
+
+	key := createRateKey(envelope.GetOrigin(), CreateEnvelopeKey(envelope), counterEvent.GetName())
+	if rate, ok := webserver.counterRates[key]; ok {
+		rate.add(sample)
+	} else {
+		webserver.counterRates[key] = newCounterRate(sample)
+	}
+}
+
+//pruneCounterRates must be called with the mutex held, it forgets instances that stopped sending counters
+func (webserver *WebServer) pruneCounterRates(now time.Time) {
+	for key, rate := range webserver.counterRates {
+		if now.Sub(time.Unix(0, rate.latest.timestamp)) > 2*minRateWindow {
+			delete(webserver.counterRates, key)
+		}
+	}
+}
+
+//calculateKPIs must be called with the mutex held, only instances still in the cache are taken into account and only
+//KPIs of origins client may read are returned
+func (webserver *WebServer) calculateKPIs(client *apiClient) []KPI {
+	kpis := make([]KPI, 0, len(kpiDefinitions))
+	for _, definition := range kpiDefinitions {
+		if !client.allowedOrigin(definition.origin) {
+			continue
+		}
+
+		var values []float64
+		for key, resource := range webserver.cache[definition.origin] {
+			if definition.rate {
+				rate, ok := webserver.counterRates[createRateKey(definition.origin, key, definition.metric)]
+				if !ok {
+					continue
+				}
+
+				if value, ok := rate.perSecond(); ok {
+					values = append(values, value)
+				}
+			} else if value, ok := resource.ValueMetrics[definition.metric]; ok {
+				values = append(values, value)
+			}
+		}
+
+		kpi := KPI{
+			Name:      definition.name,
+			Unit:      definition.unit,
+			Instances: len(values),
+		}
+
+		if len(values) == 0 {
+			kpi.Missing = []string{createMetricName(definition.origin, definition.metric)}
+		} else {
+			value := aggregations[definition.aggregation](values)
+			kpi.Value = &value
+		}
+
+		kpis = append(kpis, kpi)
+	}
+
+	return kpis
+}
+
+func (webserver *WebServer) kpisHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /kpis request")
+
+	client, ok := webserver.authorizeClient(w, r)
+	if !ok {
+		return
+	}
+
+	webserver.mutext.Lock()
+
+	kpis := webserver.calculateKPIs(client)
+	webserver.mutext.Unlock()
+
+	messageBytes, _ := json.Marshal(kpis)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /kpis call: %s", err.Error())
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+func TestKPIs(t *testing.T) {
+	server := createKPIWebServer()
+	start := time.Now().Add(-time.Minute)
+
+	server.CacheEnvelope(createKPIValueMetric(repOrigin, "0", "CapacityRemainingMemory", 4096))
+	server.CacheEnvelope(createKPIValueMetric(repOrigin, "1", "CapacityRemainingMemory", 1024))
+	server.CacheEnvelope(createKPICounterEvent(goRouterOrigin, "0", "bad_gateways", 100, start))
+	server.CacheEnvelope(createKPICounterEvent(goRouterOrigin, "0", "bad_gateways", 160, start.Add(30*time.Second)))
+	server.CacheEnvelope(createKPICounterEvent(goRouterOrigin, "1", "bad_gateways", 10, start))
+	server.CacheEnvelope(createKPICounterEvent(goRouterOrigin, "1", "bad_gateways", 40, start.Add(30*time.Second)))
+
+	kpis := make(map[string]KPI)
+	for _, kpi := range server.calculateKPIs(nil) {
+		kpis[kpi.Name] = kpi
+	}
+
+	checkKPIValue(t, kpis["DiegoCellMemoryRemainingMin"], 1024)
+	checkKPIValue(t, kpis["DiegoCellMemoryRemainingTotal"], 5120)
+	checkKPIValue(t, kpis["GorouterBadGatewayRate"], 3)
+
+	kpi := kpis["UAARequestRate"]
+	t.Logf("Checking missing input of %s... (expected value: uaa.requests.global.completed.count)", kpi.Name)
+	if kpi.Value != nil || len(kpi.Missing) != 1 || kpi.Missing[0] != "uaa.requests.global.completed.count" {
+		t.Errorf("Expected missing uaa.requests.global.completed.count, but received %+v", kpi)
+	}
+}
+
+func TestCounterRateWindow(t *testing.T) {
+	start := time.Now()
+	rate := newCounterRate(counterSample{total: 0, timestamp: start.UnixNano()})
+
+	t.Log("Checking rate of a single sample is unknown...")
+	if _, ok := rate.perSecond(); ok {
+		t.Errorf("Expected no rate for a single sample")
+	}
+
+	for i := 1; i <= 6; i++ {
+		rate.add(counterSample{total: float64(i * 30), timestamp: start.Add(time.Duration(i) * 30 * time.Second).UnixNano()})
+	}
+
+	elapsed := time.Duration(rate.latest.timestamp - rate.previous.timestamp)
+	t.Logf("Checking rate window after three minutes... (expected value: between %v and %v)", minRateWindow, 2*minRateWindow)
+	if elapsed < minRateWindow || elapsed > 2*minRateWindow {
+		t.Errorf("Expected rate window between %v and %v, but received %v", minRateWindow, 2*minRateWindow, elapsed)
+	}
+
+	t.Log("Checking counter reset restarts the rate...")
+	rate.add(counterSample{total: 5, timestamp: start.Add(4 * time.Minute).UnixNano()})
+	if _, ok := rate.perSecond(); ok {
+		t.Errorf("Expected no rate after a counter reset")
+	}
+}
+
+func checkKPIValue(t *testing.T, kpi KPI, expected float64) {
+	t.Logf("Checking value of %s... (expected value: %v)", kpi.Name, expected)
+	if kpi.Value == nil || *kpi.Value != expected {
+		t.Errorf("Expected %s to be %v, but received %+v", kpi.Name, expected, kpi)
+	}
+}
+
+func createKPIWebServer() *WebServer {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	return &WebServer{
+		logger:       logger.New(defaultLogDirectory, webserverLogFile, webserverLogName, webserverLogLevel),
+		cache:        make(map[string]map[string]Resource),
+		counterRates: make(map[string]*counterRate),
+		router:       http.NewServeMux(),
+		done:         make(chan struct{}),
+		selfStats:    stats.New(),
+		rateStart:    time.Now(),
+	}
+}
+
+func createKPIValueMetric(origin string, index string, name string, value float64) *events.Envelope {
+	envelope := createKPIEnvelope(origin, index, events.Envelope_ValueMetric, time.Now())
+	envelope.ValueMetric = &events.ValueMetric{Name: &name, Value: &value}
+	return envelope
+}
+
+func createKPICounterEvent(origin string, index string, name string, total uint64, timestamp time.Time) *events.Envelope {
+	envelope := createKPIEnvelope(origin, index, events.Envelope_CounterEvent, timestamp)
+	envelope.CounterEvent = &events.CounterEvent{Name: &name, Total: &total}
+	return envelope
+}
+
+func createKPIEnvelope(origin string, index string, eventType events.Envelope_EventType, timestamp time.Time) *events.Envelope {
+	deployment := "cf"
+	job := origin
+	ip := "10.0.0.1"
+	nanoseconds := timestamp.UnixNano()
+
+	return &events.Envelope{
+		Origin:     &origin,
+		EventType:  &eventType,
+		Timestamp:  &nanoseconds,
+		Deployment: &deployment,
+		Job:        &job,
+		Index:      &index,
+		Ip:         &ip,
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+const defaultListenerName = "default"
+
+//internalRoutePrefixes are only exposed on internal listeners
+var internalRoutePrefixes = []string{"/admin/", "/debug/", "/internal/"}
+
+type listenerContextKey struct{}
+
+//route is a handler registered by New, listeners choose which routes they expose
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+	origin  string //Origin of the cached resources the route serves
+}
+
+//listener is an address the web server listens on
+type listener struct {
+	name                  string
+	address               string
+	useSSL                bool
+	requireAuthentication bool
+	internal              bool
+	routes                map[string]bool
+}
+
+//createListeners falls back to a single internal listener on WebServerPort exposing every route, every listener
+//exposes the public routes regardless of its configured routes
+func createListeners(config *nozzleconfiguration.NozzleConfiguration, routes []route) ([]*listener, error) {
+	if len(config.Listeners) == 0 {
+		return []*listener{{
+			name:                  defaultListenerName,
+			address:               listenAddress(config.WebServerBindAddress, config.WebServerPort),
+			useSSL:                config.WebServerUseSSL,
+			requireAuthentication: true,
+			internal:              true,
+			routes:                map[string]bool{allEndpoints: true},
+		}}, nil
+	}
+
+	knownRoutes := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		knownRoutes[route.pattern] = true
+	}
+
+	listeners := make([]*listener, 0, len(config.Listeners))
+	names := make(map[string]bool, len(config.Listeners))
+	for _, listenerConfig := range config.Listeners {
+		if listenerConfig.Name == "" {
+			return nil, fmt.Errorf("Listener without a name")
+		}
+
+		if names[listenerConfig.Name] {
+			return nil, fmt.Errorf("Listener %s is configured twice", listenerConfig.Name)
+		}
+		names[listenerConfig.Name] = true
+
+		if listenerConfig.Port == 0 {
+			return nil, fmt.Errorf("Listener %s has no port", listenerConfig.Name)
+		}
+
+		listener := &listener{
+			name:                  listenerConfig.Name,
+			address:               listenAddress(listenerConfig.BindAddress, listenerConfig.Port),
+			useSSL:                listenerConfig.UseSSL,
+			requireAuthentication: !listenerConfig.DisableAuthentication,
+			internal:              listenerConfig.Internal,
+			routes:                make(map[string]bool),
+		}
+
+		if len(listenerConfig.Routes) == 0 {
+			listener.routes[allEndpoints] = true
+		}
+
+		for _, configRoute := range listenerConfig.Routes {
+			patterns := []string{configRoute}
+			if group, ok := config.EndpointGroups[configRoute]; ok {
+				patterns = group
+			}
+
+			for _, pattern := range patterns {
+				pattern = normalizeEndpoint(pattern)
+				if pattern != allEndpoints && !knownRoutes[pattern] {
+					return nil, fmt.Errorf("Listener %s exposes unknown route %s", listenerConfig.Name, pattern)
+				}
+
+				if isInternalRoute(pattern) && !listener.internal {
+					return nil, fmt.Errorf("Listener %s exposes internal route %s but is not internal", listenerConfig.Name, pattern)
+				}
+				listener.routes[pattern] = true
+			}
+		}
+
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+func isInternalRoute(pattern string) bool {
+	for _, prefix := range internalRoutePrefixes {
+		if strings.HasPrefix(pattern, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (listener *listener) exposes(pattern string) bool {
+	if isPublicRoute(pattern) {
+		return true
+	}
+	if isInternalRoute(pattern) && !listener.internal {
+		return false
+	}
+	return listener.routes[allEndpoints] || listener.routes[pattern]
+}
+
+//listenerHandler routes the requests of listener to the routes it exposes
+func (webserver *WebServer) listenerHandler(listener *listener) http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range webserver.routes {
+		if listener.exposes(route.pattern) {
+			mux.HandleFunc(route.pattern, route.handler)
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), listenerContextKey{}, listener)))
+	})
+}
+
+//requestListener is the listener that received r, nil for requests served through ServeHTTP
+func requestListener(r *http.Request) *listener {
+	listener, _ := r.Context().Value(listenerContextKey{}).(*listener)
+	return listener
+}
+
+func (webserver *WebServer) usesSSL() bool {
+	for _, listener := range webserver.listeners {
+		if listener.useSSL {
+			return true
+		}
+	}
+	return false
+}
+
+//serve blocks until the http.Server of listener stops, it returns nil when it is shut down
+func (webserver *WebServer) serve(listener *listener) error {
+	server := webserver.newHTTPServer(listener.address, webserver.listenerHandler(listener))
+
+	webserver.serverMutex.Lock()
+	select {
+	case <-webserver.done:
+		webserver.serverMutex.Unlock()
+		return nil
+	default:
+		webserver.servers = append(webserver.servers, server)
+	}
+	webserver.serverMutex.Unlock()
+
+	webserver.logger.Infof("Listener %s listening on %s (SSL %v, authentication %v, internal %v)",
+		listener.name, listener.address, listener.useSSL, listener.requireAuthentication, listener.internal)
+
+	var err error
+	if listener.useSSL {
+		err = server.ListenAndServeTLS("", "")
+	} else {
+		err = server.ListenAndServe()
+	}
+
+	if err == http.ErrServerClosed {
+		webserver.logger.Infof("Listener %s stopped", listener.name)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+func TestCreateListeners(t *testing.T) {
+	routes := []route{{pattern: "/gorouters"}, {pattern: "/kpis"}, {pattern: "/admin/tokens"}}
+	config := &nozzleconfiguration.NozzleConfiguration{WebServerPort: 8081, WebServerUseSSL: true}
+
+	listeners, err := createListeners(config, routes)
+	t.Log("Check if a default listener is created without configured listeners... (expecting internal listener on :8081)")
+	if err != nil || len(listeners) != 1 || listeners[0].address != ":8081" || !listeners[0].useSSL || !listeners[0].internal || !listeners[0].exposes("/admin/tokens") {
+		t.Errorf("Expected internal SSL listener on :8081, but received %+v and error %v", listeners, err)
+	}
+
+	config.EndpointGroups = map[string][]string{"metrics": {"gorouters", "/kpis"}}
+	config.Listeners = []nozzleconfiguration.ListenerConfiguration{
+		{Name: "external", Port: 8443, UseSSL: true, Routes: []string{"metrics"}},
+		{Name: "sidecar", BindAddress: "127.0.0.1", Port: 8080, DisableAuthentication: true, Internal: true},
+	}
+
+	listeners, err = createListeners(config, routes)
+	if err != nil {
+		t.Fatalf("Expected listeners, but received error %s", err.Error())
+	}
+
+	external, sidecar := listeners[0], listeners[1]
+	t.Log("Check if endpoint groups are expanded into routes... (expecting /gorouters and /kpis)")
+	if !external.exposes("/gorouters") || !external.exposes("/kpis") || external.exposes("/admin/tokens") || !external.requireAuthentication {
+		t.Errorf("Expected external listener to expose /gorouters and /kpis with authentication, but received %+v", external)
+	}
+
+	t.Log("Check if internal listener exposes every route... (expecting /admin/tokens on 127.0.0.1:8080)")
+	if sidecar.address != "127.0.0.1:8080" || !sidecar.exposes("/admin/tokens") || sidecar.requireAuthentication {
+		t.Errorf("Expected unauthenticated internal listener on 127.0.0.1:8080, but received %+v", sidecar)
+	}
+
+	invalid := map[string][]nozzleconfiguration.ListenerConfiguration{
+		"missing name":   {{Port: 8080}},
+		"duplicate name": {{Name: "api", Port: 8080}, {Name: "api", Port: 8081}},
+		"missing port":   {{Name: "api"}},
+		"unknown route":  {{Name: "api", Port: 8080, Routes: []string{"/unknown"}}},
+		"external admin": {{Name: "api", Port: 8080, Routes: []string{"/admin/tokens"}}},
+	}
+
+	for name, listenerConfigs := range invalid {
+		config.Listeners = listenerConfigs
+		t.Logf("Check if listener with %s is rejected... (expected error)", name)
+		if _, err := createListeners(config, routes); err == nil {
+			t.Errorf("Expected error for listener with %s, but received none", name)
+		}
+	}
+}
+
+func TestListenerRoutes(t *testing.T) {
+	server := createAuthWebServer()
+	server.cache[goRouterOrigin] = map[string]Resource{}
+	server.handleFunc("/gorouters", server.gorouterHandler)
+	server.handleFunc("/admin/tokens", server.tokenCountsHandler)
+
+	external := &listener{name: "external", requireAuthentication: true, routes: map[string]bool{allEndpoints: true}}
+	sidecar := &listener{name: "sidecar", internal: true, routes: map[string]bool{allEndpoints: true}}
+
+	cases := []struct {
+		listener *listener
+		path     string
+		status   int
+	}{
+		{external, "/gorouters", http.StatusUnauthorized},
+		{external, "/admin/tokens", http.StatusNotFound},
+		{sidecar, "/gorouters", http.StatusOK},
+		{sidecar, "/admin/tokens", http.StatusOK},
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest("GET", "http://localhost"+c.path, nil)
+		recorder := httptest.NewRecorder()
+		server.listenerHandler(c.listener).ServeHTTP(recorder, request)
+
+		t.Logf("Check %s without token on listener %s... (expecting status code: %v)", c.path, c.listener.name, c.status)
+		if recorder.Code != c.status {
+			t.Errorf("Expecting status code %v, but received %v", c.status, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const headerRetryAfterKey = "Retry-After"
+
+func ipRateLimitKey(r *http.Request) string {
+	return fmt.Sprintf("ip %s", clientIP(r))
+}
+
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+func userRateLimitKey(user string) string {
+	return fmt.Sprintf("user %s", user)
+}
+
+//loginRateLimitKey locks out the credential checks of user, requests with a valid token of user are not affected
+func loginRateLimitKey(user string) string {
+	return fmt.Sprintf("login %s", user)
+}
+
+//limitRequest answers the request with 429 when key is rate limited or locked out
+func (webserver *WebServer) limitRequest(w http.ResponseWriter, r *http.Request, key string) bool {
+	if webserver.limiter == nil {
+		return true
+	}
+
+	allowed, retryAfter := webserver.limiter.Allow(key)
+	if allowed {
+		return true
+	}
+
+	webserver.tooManyRequests(w, r, key, retryAfter)
+	return false
+}
+
+//limitLogin answers the request with 429 when the logins of user are locked out
+func (webserver *WebServer) limitLogin(w http.ResponseWriter, r *http.Request, user string) bool {
+	if webserver.limiter == nil {
+		return true
+	}
+
+	key := loginRateLimitKey(user)
+	retryAfter := webserver.limiter.Lockout(key)
+	if retryAfter <= 0 {
+		return true
+	}
+
+	webserver.tooManyRequests(w, r, key, retryAfter)
+	return false
+}
+
+func (webserver *WebServer) tooManyRequests(w http.ResponseWriter, r *http.Request, key string, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	webserver.logger.Warnf("Denied %s request of %s, retry after %d seconds", r.URL.Path, key, seconds)
+	w.Header().Set(headerRetryAfterKey, strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	io.WriteString(w, fmt.Sprintf("Too many requests, retry after %d seconds", seconds))
+}
+
+//recordFailure counts a failed login towards the lockout of every key. Failures are never keyed on the client IP,
+//behind gorouter all clients share its IP and would be locked out together
+func (webserver *WebServer) recordFailure(keys ...string) {
+	if webserver.limiter == nil {
+		return
+	}
+
+	for _, key := range keys {
+		if lockout := webserver.limiter.Failure(key); lockout > 0 {
+			webserver.logger.Warnf("Locked out %s for %v after repeated failures", key, lockout)
+		}
+	}
+}
+
+func (webserver *WebServer) recordSuccess(keys ...string) {
+	if webserver.limiter == nil {
+		return
+	}
+
+	for _, key := range keys {
+		webserver.limiter.Success(key)
+	}
+}
+
+func (webserver *WebServer) rateLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /admin/ratelimits request")
+
+	if !webserver.authorizeRequest(w, r) {
+		return
+	}
+
+	if webserver.limiter == nil {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, "Rate limiting is not enabled")
+		return
+	}
+
+	messageBytes, _ := json.Marshal(webserver.limiter.Stats())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /admin/ratelimits call: %s", err.Error())
+	}
+}
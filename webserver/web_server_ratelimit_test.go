@@ -0,0 +1,112 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/ratelimit"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
+)
+
+func TestLoginLockout(t *testing.T) {
+	server := createKPIWebServer()
+	server.config = &nozzleconfiguration.NozzleConfiguration{
+		UAAUsername: "user",
+		UAAPassword: "password",
+		RateLimit:   nozzleconfiguration.RateLimitConfiguration{MaxFailures: 2, LockoutSeconds: 30},
+	}
+	server.tokens = webtoken.NewStore(time.Minute, 0, nil, nil)
+	server.limiter = ratelimit.New(&server.config.RateLimit, nil)
+
+	expectedStatuses := []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}
+	for i, expectedStatus := range expectedStatuses {
+		recorder := sendTokenRequest(server, "10.0.0.1:1234", "user", "wrong-password")
+
+		t.Logf("Check if server responses to bad credential token request %d... (expecting status code: %v)", i+1, expectedStatus)
+		if recorder.Code != expectedStatus {
+			t.Errorf("Expecting status code %v, but received %v", expectedStatus, recorder.Code)
+		}
+	}
+
+	t.Log("Check if locked out user is denied from another IP... (expecting Retry-After: 30)")
+	recorder := sendTokenRequest(server, "10.0.0.2:1234", "user", "password")
+	if recorder.Code != http.StatusTooManyRequests || recorder.Header().Get(headerRetryAfterKey) != "30" {
+		t.Errorf("Expecting status code %v with Retry-After 30, but received %v with Retry-After %s", http.StatusTooManyRequests, recorder.Code, recorder.Header().Get(headerRetryAfterKey))
+	}
+
+	t.Logf("Check if other users behind the same IP can still log in... (expecting status code: %v)", http.StatusUnauthorized)
+	recorder = sendTokenRequest(server, "10.0.0.1:1234", "other", "wrong-password")
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, recorder.Code)
+	}
+
+	stats := server.limiter.Stats()
+	if stats.Failures != 3 || stats.Lockouts != 1 {
+		t.Errorf("Expecting 3 failures and 1 lockout, but received %+v", stats)
+	}
+}
+
+func TestLoginLockoutKeepsTokens(t *testing.T) {
+	server := createAuthWebServer()
+	server.config.RateLimit = nozzleconfiguration.RateLimitConfiguration{MaxFailures: 1, LockoutSeconds: 30}
+	server.limiter = ratelimit.New(&server.config.RateLimit, nil)
+	_, tokenString, _ := server.tokens.Create("user")
+
+	request := createAuthRequest("")
+	request.RemoteAddr = "10.0.0.1:1234"
+	request.SetBasicAuth("user", "wrong-password")
+	authenticateTestRequest(server, request)
+
+	request = createAuthRequest("")
+	request.RemoteAddr = "10.0.0.2:1234"
+	request.SetBasicAuth("user", "password")
+
+	t.Logf("Check if logins of the locked out user are denied... (expecting status code: %v)", http.StatusTooManyRequests)
+	if _, recorder, ok := authenticateTestRequest(server, request); ok || recorder.Code != http.StatusTooManyRequests {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusTooManyRequests, recorder.Code)
+	}
+
+	request = createAuthRequest("Bearer " + tokenString)
+	request.RemoteAddr = "10.0.0.2:1234"
+
+	t.Log("Check if a valid token of the locked out user is still accepted... (expecting user: user)")
+	if user, recorder, ok := authenticateTestRequest(server, request); !ok || user != "user" {
+		t.Errorf("Expected user user, but received status code %v", recorder.Code)
+	}
+}
+
+func TestBasicAuthorizationRateLimit(t *testing.T) {
+	server := createAuthWebServer()
+	server.config.RateLimit = nozzleconfiguration.RateLimitConfiguration{RequestsPerSecond: 0.001, Burst: 1}
+	server.limiter = ratelimit.New(&server.config.RateLimit, nil)
+
+	request := createAuthRequest("")
+	request.SetBasicAuth("user", "password")
+
+	t.Log("Check if a basic authorization login takes one request of the user... (expecting user: user)")
+	if user, recorder, ok := authenticateTestRequest(server, request); !ok || user != "user" {
+		t.Errorf("Expected user user, but received status code %v", recorder.Code)
+	}
+
+	t.Log("Check if requests are counted once... (expecting allowed: 2)")
+	if stats := server.limiter.Stats(); stats.Allowed != 2 || stats.RateLimited != 0 {
+		t.Errorf("Expected 2 allowed and no rate limited requests, but received %+v", stats)
+	}
+}
+
+func sendTokenRequest(server *WebServer, remoteAddr string, username string, password string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest("GET", "https://localhost/token", nil)
+	request.RemoteAddr = remoteAddr
+	request.Header.Add(headerUsernameKey, username)
+	request.Header.Add(headerPasswordKey, password)
+
+	recorder := httptest.NewRecorder()
+	server.tokenHandler(recorder, request)
+	return recorder
+}
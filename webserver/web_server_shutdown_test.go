@@ -0,0 +1,151 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/alerting"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+)
+
+func TestServeHTTP(t *testing.T) {
+	first := createLibraryWebServer(t, 0)
+	second := createLibraryWebServer(t, 0)
+
+	for i, server := range []*WebServer{first, second} {
+		testServer := httptest.NewServer(server)
+
+		request, _ := http.NewRequest("GET", testServer.URL+"/token", nil)
+		request.Header.Set(headerUsernameKey, "user")
+		request.Header.Set(headerPasswordKey, "password")
+		response, err := http.DefaultClient.Do(request)
+		testServer.Close()
+
+		t.Logf("Check if web server %d serves /token as http.Handler... (expecting status code: %v)", i+1, http.StatusOK)
+		if err != nil {
+			t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+		}
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusOK || response.Header.Get(headerTokenKey) == "" {
+			t.Errorf("Expecting status code %v with token, but received %v", http.StatusOK, response.StatusCode)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	port := freePort(t)
+	server := createLibraryWebServer(t, port)
+	errors := server.Start("", "")
+
+	address := fmt.Sprintf("http://127.0.0.1:%d/kpis", port)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		response, err := http.Get(address)
+		if err == nil {
+			response.Body.Close()
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected web server to listen on port %d, but received error %s", port, err.Error())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Log("Check if web server shuts down... (expecting no error)")
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Expected no error, but received %s", err.Error())
+	}
+
+	t.Log("Check if error channel is closed without error... (expecting closed channel)")
+	select {
+	case err, ok := <-errors:
+		if ok {
+			t.Errorf("Expected closed channel, but received error %v", err)
+		}
+	case <-ctx.Done():
+		t.Error("Expected closed channel, but it is still open")
+	}
+
+	t.Log("Check if web server stopped listening... (expected error)")
+	if response, err := http.Get(address); err == nil {
+		response.Body.Close()
+		t.Errorf("Expected connection error, but received status code %v", response.StatusCode)
+	}
+}
+
+func TestShutdownSendsAlertNotifications(t *testing.T) {
+	notifications := make(chan struct{}, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		//Slow enough that the notification is still queued when Shutdown is called
+		time.Sleep(100 * time.Millisecond)
+		notifications <- struct{}{}
+	}))
+	defer webhook.Close()
+
+	server := createLibraryWebServer(t, 0)
+	alertingConfig := &nozzleconfiguration.AlertingConfiguration{
+		WebhookURLs: []string{webhook.URL},
+		Rules: []nozzleconfiguration.AlertRuleConfiguration{
+			{Origin: goRouterOrigin, Metric: "metric", Comparator: ">", Threshold: 50},
+		},
+	}
+
+	engine, err := alerting.New(alertingConfig, time.Minute, server.logger)
+	if err != nil {
+		t.Fatalf("Error creating alerting engine: %s", err.Error())
+	}
+	server.alerts = engine
+
+	cacheEnvelope(goRouterOrigin, server)
+	server.evaluateAlerts(time.Now())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Log("Check if web server shuts down... (expecting no error)")
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Expected no error, but received %s", err.Error())
+	}
+
+	t.Log("Check if the queued firing notification was sent before Shutdown returned... (expecting 1 notification)")
+	if len(notifications) != 1 {
+		t.Errorf("Expected 1 notification, but received %d", len(notifications))
+	}
+}
+
+func createLibraryWebServer(t *testing.T, port uint32) *WebServer {
+	logger.CreateLogDirectory(defaultLogDirectory)
+	config := &nozzleconfiguration.NozzleConfiguration{
+		UAAUsername:          "user",
+		UAAPassword:          "password",
+		DisableAccessControl: true,
+		WebServerBindAddress: "127.0.0.1",
+		WebServerPort:        port,
+	}
+
+	return New(config, logger.New(defaultLogDirectory, webserverLogFile, webserverLogName, webserverLogLevel))
+}
+
+func freePort(t *testing.T) uint32 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error finding free port: %s", err.Error())
+	}
+	defer listener.Close()
+
+	return uint32(listener.Addr().(*net.TCPAddr).Port)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+//countEnvelope must be called with the mutex held
+func (webserver *WebServer) countEnvelope(envelope *events.Envelope) {
+	eventType := envelope.GetEventType()
+
+	webserver.selfStats.Increment(stats.EnvelopesReceived)
+	webserver.selfStats.Increment(stats.Name(stats.EnvelopesReceivedByEventType, eventType.String()))
+	webserver.selfStats.Increment(stats.Name(stats.EnvelopesReceivedByOrigin, envelope.GetOrigin()))
+
+	if _, ok := events.Envelope_EventType_name[int32(eventType)]; !ok {
+		webserver.selfStats.Increment(stats.EnvelopesUnknown)
+	} else if eventType != events.Envelope_ValueMetric && eventType != events.Envelope_CounterEvent {
+		webserver.selfStats.Increment(stats.EnvelopesIgnored)
+	}
+}
+
+//RecordOwnEnvelope counts an envelope the nozzle emitted itself and read back from the firehose, it is not cached
+func (webserver *WebServer) RecordOwnEnvelope() {
+	webserver.selfStats.Increment(stats.EnvelopesOwn)
+}
+
+//countRequest counts the API requests by path and status code
+func (webserver *WebServer) countRequest(r *http.Request, status int) {
+	webserver.selfStats.Increment(stats.Name(stats.APIRequests, r.URL.Path, strconv.Itoa(status)))
+}
+
+//updateEnvelopeRate must be called with the mutex held, it sets the envelopes per second since its last call
+func (webserver *WebServer) updateEnvelopeRate(now time.Time) {
+	received := webserver.selfStats.Counter(stats.EnvelopesReceived)
+	elapsed := now.Sub(webserver.rateStart).Seconds()
+	if elapsed > 0 {
+		webserver.selfStats.Set(stats.EnvelopesPerSecond, float64(received-webserver.rateReceived)/elapsed)
+	}
+
+	webserver.rateStart = now
+	webserver.rateReceived = received
+}
+
+//RecordFlush counts a flush of the metric cache that took duration
+func (webserver *WebServer) RecordFlush(duration time.Duration) {
+	webserver.selfStats.Increment(stats.Flushes)
+	webserver.selfStats.Set(stats.FlushDuration, float64(duration)/float64(time.Millisecond))
+}
+
+//SelfStats returns the counters and gauges the nozzle keeps about itself
+func (webserver *WebServer) SelfStats() stats.Snapshot {
+	webserver.mutext.Lock()
+	var resources, series int
+	for _, resourceMap := range webserver.cache {
+		for _, resource := range resourceMap {
+			resources++
+			series += len(resource.ValueMetrics) + len(resource.CounterMetrics)
+		}
+	}
+	webserver.mutext.Unlock()
+
+	webserver.selfStats.Set(stats.CacheResources, float64(resources))
+	webserver.selfStats.Set(stats.CacheSeries, float64(series))
+
+	if webserver.tokens != nil {
+		var tokens int
+		for _, count := range webserver.tokens.Counts() {
+			tokens += count
+		}
+		webserver.selfStats.Set(stats.TokensActive, float64(tokens))
+	}
+
+	return webserver.selfStats.Snapshot()
+}
+
+func (webserver *WebServer) statsHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /internal/stats request")
+
+	if !webserver.authorizeRequest(w, r) {
+		return
+	}
+
+	messageBytes, _ := json.Marshal(webserver.SelfStats())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /internal/stats call: %s", err.Error())
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/stats"
+	"github.com/cloudfoundry/sonde-go/events"
+)
+
+func TestSelfStats(t *testing.T) {
+	server := createHealthWebServer()
+	server.handleFunc("/internal/stats", server.statsHandler)
+
+	server.CacheEnvelope(createKPIValueMetric(repOrigin, "0", "CapacityRemainingMemory", 4096))
+	server.CacheEnvelope(createKPIValueMetric(repOrigin, "0", "CapacityTotalMemory", 8192))
+	server.CacheEnvelope(createKPICounterEvent(goRouterOrigin, "0", "requests", 10, time.Now()))
+	server.CacheEnvelope(createKPIEnvelope(goRouterOrigin, "0", events.Envelope_LogMessage, time.Now()))
+	server.SetFirehoseState(FirehoseConnected)
+	server.SetFirehoseState(FirehoseConnected)
+
+	for _, path := range []string{"/kpis", "/internal/stats"} {
+		request, _ := http.NewRequest("GET", "http://localhost"+path, nil)
+		server.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	snapshot := server.SelfStats()
+
+	counters := map[string]uint64{
+		stats.EnvelopesReceived:                                      4,
+		stats.Name(stats.EnvelopesReceivedByOrigin, repOrigin):       2,
+		stats.Name(stats.EnvelopesReceivedByEventType, "LogMessage"): 1,
+		stats.EnvelopesIgnored:                                       1,
+		stats.FirehoseReconnects:                                     1,
+		stats.Name(stats.APIRequests, "/kpis", "401"):                1,
+		stats.Name(stats.APIRequests, "/internal/stats", "401"):      1,
+	}
+
+	for name, expected := range counters {
+		t.Logf("Check counter %s... (expecting %d)", name, expected)
+		if snapshot.Counters[name] != expected {
+			t.Errorf("Expected %s of %d, but received %d", name, expected, snapshot.Counters[name])
+		}
+	}
+
+	t.Log("Check cache gauges... (expecting 2 resources with 3 series)")
+	if snapshot.Gauges[stats.CacheResources] != 2 || snapshot.Gauges[stats.CacheSeries] != 3 {
+		t.Errorf("Expected 2 resources with 3 series, but received %v", snapshot.Gauges)
+	}
+
+	server.rateStart = time.Now().Add(-2 * time.Second)
+	server.ClearCache()
+	server.RecordFlush(1500 * time.Microsecond)
+	snapshot = server.SelfStats()
+
+	t.Log("Check envelope rate and flush gauges after flush... (expecting about 2 envelopes per second and 1.5 ms)")
+	if rate := snapshot.Gauges[stats.EnvelopesPerSecond]; rate < 1.9 || rate > 2 ||
+		snapshot.Gauges[stats.FlushDuration] != 1.5 || snapshot.Counters[stats.Flushes] != 1 {
+		t.Errorf("Expected about 2 envelopes per second and a 1.5 ms flush, but received %v", snapshot)
+	}
+}
+
+func TestStatsRouteIsInternal(t *testing.T) {
+	server := createHealthWebServer()
+	server.handleFunc("/internal/stats", server.statsHandler)
+
+	external := &listener{name: "external", routes: map[string]bool{allEndpoints: true}}
+	request, _ := http.NewRequest("GET", "http://localhost/internal/stats", nil)
+	recorder := httptest.NewRecorder()
+	server.listenerHandler(external).ServeHTTP(recorder, request)
+
+	t.Logf("Check /internal/stats on external listener... (expecting status code: %v)", http.StatusNotFound)
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusNotFound, recorder.Code)
+	}
+}
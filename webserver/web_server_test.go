@@ -4,15 +4,18 @@
 package webserver
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
 	"net/http"
 	"crypto/tls"
 	"time"
 
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/alerting"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/logger"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
 	"github.com/BlueMedora/bluemedora-firehose-nozzle/testhelpers"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
 	"github.com/cloudfoundry/sonde-go/events"
 )
 
@@ -408,6 +411,42 @@ func TestGoRouterEndpoint(t *testing.T) {
 	endPointTest(t, client, token, config.WebServerPort, goRouterOrigin, "gorouters", server)
 }
 
+func TestAlertsEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initalize in first test")
+	}
+	
+	client := createHTTPClient(t)
+	
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+	
+	alertsEndPointTest(t, client, token, config.WebServerPort, server)
+}
+
+func TestCheckEndpoint(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initalize in first test")
+	}
+	
+	client := createHTTPClient(t)
+	
+	checkEndPointTest(t, client, config.WebServerPort, server)
+}
+
+func TestRevokeToken(t *testing.T) {
+	if server == nil {
+		t.Fatalf("Server failed to initalize in first test")
+	}
+	
+	client := createHTTPClient(t)
+	
+	//Retrieve token for other endpoint test
+	token := getToken(t, client, config)
+	
+	revokeTokenTest(t, client, token, config.WebServerPort)
+}
+
 func TestTokenTimeout(t *testing.T) {
 	if server == nil {
 		t.Fatalf("Server failed to initalize in first test")
@@ -415,11 +454,18 @@ func TestTokenTimeout(t *testing.T) {
 	
 	client := createHTTPClient(t)
 	
+	//The frozen clock store must not leak into the tests run after this one
+	tokens := server.tokens
+	defer func() { server.tokens = tokens }()
+	
+	now := time.Now()
+	server.tokens = webtoken.NewStore(time.Minute, time.Hour, func() time.Time { return now }, server.TokenTimeout)
+	
 	//Retrieve token for other endpoint test
 	token := getToken(t, client, config)
 	
-	t.Log("Waiting 3 minutes to enusre token invalidates")
-	time.Sleep(time.Duration(3) * time.Minute)
+	t.Log("Advancing the token clock 3 minutes to enusre token invalidates")
+	now = now.Add(time.Duration(3) * time.Minute)
 	
 	request := createResourceRequest(t, token, config.WebServerPort, "gorouters")
 	
@@ -529,6 +575,110 @@ func noTokenEndPointTest(t *testing.T, client *http.Client, port uint32, server
 	}
 }
 
+func alertsEndPointTest(t *testing.T, client *http.Client, token string, port uint32, server *WebServer) {
+	alertingConfig := &nozzleconfiguration.AlertingConfiguration {
+		Rules: []nozzleconfiguration.AlertRuleConfiguration {
+			{Origin: goRouterOrigin, Metric: "metric", Comparator: ">", Threshold: 50},
+		},
+	}
+	
+	engine, err := alerting.New(alertingConfig, time.Minute, server.logger)
+	if err != nil {
+		t.Fatalf("Error creating alerting engine: %s", err.Error())
+	}
+	
+	server.alerts = engine
+	defer func() { server.alerts = nil }()
+	
+	cacheEnvelope(goRouterOrigin, server)
+	server.evaluateAlerts(time.Now())
+	
+	request := createResourceRequest(t, token, port, "alerts")
+	
+	t.Logf("Check if server response to valid /alerts request... (expecting status code: %v)", http.StatusOK)
+	response, err := client.Do(request)
+	
+	if err != nil {
+		t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	}
+	
+	var alerts []alerting.Alert
+	json.NewDecoder(response.Body).Decode(&alerts)
+	response.Body.Close()
+	
+	t.Logf("Check if gorouter metric above threshold is firing... (expecting state: %s)", alerting.StateFiring)
+	if len(alerts) != 1 || alerts[0].State != alerting.StateFiring {
+		t.Errorf("Expecting one firing alert, but received %+v", alerts)
+	}
+}
+
+func checkEndPointTest(t *testing.T, client *http.Client, port uint32, server *WebServer) {
+	server.config.CheckAPIKey = "check-key"
+	defer func() { server.config.CheckAPIKey = "" }()
+	
+	cacheEnvelope(goRouterOrigin, server)
+	
+	for apiKey, expectedStatus := range map[string]int{"check-key": http.StatusServiceUnavailable, "wrong-key": http.StatusUnauthorized} {
+		url := fmt.Sprintf("https://localhost:%d/check?origin=%s&metric=metric&crit=50", port, goRouterOrigin)
+		request, _ := http.NewRequest("GET", url, nil)
+		request.Header.Add(apiKeyHeader, apiKey)
+		
+		t.Logf("Check if server response to /check request with api key %s... (expecting status code: %v)", apiKey, expectedStatus)
+		response, err := client.Do(request)
+		
+		if err != nil {
+			t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+		} else if response.StatusCode != expectedStatus {
+			t.Errorf("Expecting status code %v, but received %v", expectedStatus, response.StatusCode)
+		}
+	}
+}
+
+func revokeTokenTest(t *testing.T, client *http.Client, token string, port uint32) {
+	request := createResourceRequest(t, token, port, "admin/tokens")
+	
+	t.Logf("Check if server response to valid /admin/tokens request... (expecting status code: %v)", http.StatusOK)
+	response, err := client.Do(request)
+	if err != nil {
+		t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expecting status code %v, but received %v", http.StatusOK, response.StatusCode)
+	}
+	
+	var counts TokenCounts
+	json.NewDecoder(response.Body).Decode(&counts)
+	response.Body.Close()
+	
+	if counts.ActiveTokens < 1 || counts.Users[config.UAAUsername] < 1 {
+		t.Errorf("Expecting at least one active token of %s, but received %+v", config.UAAUsername, counts)
+	}
+	
+	for _, expectedStatus := range []int{http.StatusNoContent, http.StatusUnauthorized} {
+		request = createResourceRequest(t, token, port, "token")
+		request.Method = "DELETE"
+		
+		t.Logf("Check if server response to token revocation... (expecting status code: %v)", expectedStatus)
+		response, err = client.Do(request)
+		if err != nil {
+			t.Errorf("Error occured while revoking token: %s", err.Error())
+		} else if response.StatusCode != expectedStatus {
+			t.Errorf("Expecting status code %v, but received %v", expectedStatus, response.StatusCode)
+		}
+	}
+	
+	request = createResourceRequest(t, token, port, "gorouters")
+	
+	t.Logf("Check if server response to revoked token usage... (expecting status code: %v)", http.StatusUnauthorized)
+	response, err = client.Do(request)
+	if err != nil {
+		t.Errorf("Error occured while hitting endpoint: %s", err.Error())
+	} else if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, response.StatusCode)
+	}
+}
+
 func resourcePutEndPointTest(t *testing.T, client *http.Client, port uint32) {
 	request, _ := http.NewRequest("PUT", fmt.Sprintf("https://localhost:%d/%s", port, "gorouters"), nil)
 	
@@ -566,6 +716,12 @@ func createWebServer(t *testing.T) (*WebServer, *nozzleconfiguration.NozzleConfi
 	if err != nil {
 		t.Fatalf("Error while loading configuration: %s", err.Error())
 	}
+	
+	//Logins are checked against the configured UAA user instead of a real UAA
+	config.DisableAccessControl = true
+	
+	//Every test requests tokens from localhost, rate limits are covered by web_server_ratelimit_test.go
+	config.RateLimit.Enabled = false
 
 	t.Log("Created webserver")
 	return New(config, logger), config
@@ -0,0 +1,138 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/certloader"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/cloudfoundry/gosteno"
+)
+
+//createTLSConfig verifies client certificates against the configured CA bundle when they are enabled. They are only
+//asked for during the handshake, so /token and the probes work without one, authenticateRequest requires them
+func createTLSConfig(config *nozzleconfiguration.ClientCertificateConfiguration, logger *gosteno.Logger) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if !config.Enabled {
+		return tlsConfig, nil
+	}
+
+	caBytes, err := ioutil.ReadFile(getAbsolutePath(config.CAFile, logger))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read client CA file: %s", err)
+	}
+
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caBytes) {
+		return nil, fmt.Errorf("No certificates found in client CA file %s", config.CAFile)
+	}
+
+	tlsConfig.ClientCAs = clientCAs
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+
+	if len(config.AllowedNames) > 0 {
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			//Also called for clients without a certificate
+			if len(verifiedChains) == 0 {
+				return nil
+			}
+
+			certificate := verifiedChains[0][0]
+			if _, ok := allowedCertificateName(certificate, config.AllowedNames); ok {
+				return nil
+			}
+
+			logger.Warnf("Rejected client certificate of %s, none of its names %v is allowed", certificate.Subject.CommonName, certificateNames(certificate))
+			return fmt.Errorf("client certificate of %s is not allowed", certificate.Subject.CommonName)
+		}
+	}
+
+	logger.Infof("Requiring client certificates signed by %s", config.CAFile)
+	return tlsConfig, nil
+}
+
+//ensureCertificate generates a self-signed certificate when it is enabled and the certificate or key is missing
+func (webserver *WebServer) ensureCertificate(certPath string, keyPath string) error {
+	config := &webserver.config.SelfSignedCertificate
+	if !config.Enabled {
+		return nil
+	}
+
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	if certErr == nil && keyErr == nil {
+		return nil
+	}
+
+	if certErr == nil || keyErr == nil {
+		webserver.logger.Warnf("Only one of %s and %s exists, replacing both with a self-signed certificate", certPath, keyPath)
+	}
+
+	if len(config.Hosts) == 0 {
+		config.Hosts = []string{defaultSelfSignedHost}
+	}
+
+	if config.ValidDays == 0 {
+		config.ValidDays = defaultSelfSignedValidDays
+	}
+
+	certificate, err := certloader.GenerateSelfSigned(certPath, keyPath, config.Hosts, time.Duration(config.ValidDays)*24*time.Hour)
+	if err != nil {
+		return err
+	}
+
+	webserver.logger.Infof("Generated self-signed TLS certificate for %v valid until %s with SHA-256 fingerprint %s",
+		config.Hosts, certificate.NotAfter.Format(time.RFC3339), certloader.Fingerprint(certificate))
+	return nil
+}
+
+//certificateNames are the common name and subject alternative names of certificate
+func certificateNames(certificate *x509.Certificate) []string {
+	var names []string
+	if certificate.Subject.CommonName != "" {
+		names = append(names, certificate.Subject.CommonName)
+	}
+
+	names = append(names, certificate.DNSNames...)
+	names = append(names, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		names = append(names, uri.String())
+	}
+
+	return names
+}
+
+//allowedCertificateName is the first name of certificate in allowedNames, or its first name when allowedNames is empty
+func allowedCertificateName(certificate *x509.Certificate, allowedNames []string) (string, bool) {
+	for _, name := range certificateNames(certificate) {
+		if len(allowedNames) == 0 {
+			return name, true
+		}
+
+		for _, allowedName := range allowedNames {
+			if name == allowedName {
+				return name, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+//certificateUser is the allowed name of the verified client certificate of the request
+func (webserver *WebServer) certificateUser(r *http.Request) (string, bool) {
+	config := &webserver.config.ClientCertificates
+	if !config.Enabled || r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", false
+	}
+
+	return allowedCertificateName(r.TLS.VerifiedChains[0][0], config.AllowedNames)
+}
@@ -0,0 +1,238 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/nozzleconfiguration"
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
+)
+
+func TestClientCertificates(t *testing.T) {
+	caCert, caKey := createTestCertificate(t, "test-ca", nil, nil)
+	otherCACert, otherCAKey := createTestCertificate(t, "other-ca", nil, nil)
+
+	directory, err := ioutil.TempDir("", "client-ca")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %s", err.Error())
+	}
+	defer os.RemoveAll(directory)
+
+	caFile := filepath.Join(directory, "ca.pem")
+	ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw}), 0600)
+
+	server := createKPIWebServer()
+	server.config = &nozzleconfiguration.NozzleConfiguration{
+		ClientCertificates: nozzleconfiguration.ClientCertificateConfiguration{
+			Enabled:      true,
+			CAFile:       caFile,
+			AllowedNames: []string{"metrics-client"},
+		},
+	}
+	server.tokens = webtoken.NewStore(time.Minute, 0, nil, nil)
+
+	server.tlsConfig, err = createTLSConfig(&server.config.ClientCertificates, server.logger)
+	if err != nil {
+		t.Fatalf("Expected TLS config, but received error %s", err.Error())
+	}
+
+	testServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, ok := server.authenticateRequest(w, r); ok {
+			io.WriteString(w, user)
+		}
+	}))
+	testServer.TLS = server.tlsConfig
+	testServer.StartTLS()
+	defer testServer.Close()
+
+	clients := map[string]tls.Certificate{
+		"allowed name":   createClientCertificate(t, "metrics-client", caCert, caKey),
+		"other name":     createClientCertificate(t, "other-client", caCert, caKey),
+		"other CA":       createClientCertificate(t, "metrics-client", otherCACert, otherCAKey),
+		"no certificate": {},
+	}
+
+	for name, certificate := range clients {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{certificate},
+		}}}
+
+		response, err := client.Get(testServer.URL)
+		//Clients only present certificates of the CAs the server asks for, so a certificate of another CA is not sent
+		if name == "no certificate" || name == "other CA" {
+			t.Logf("Check if client with %s is not authenticated... (expecting status code: %v)", name, http.StatusUnauthorized)
+			if err != nil {
+				t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+			}
+			response.Body.Close()
+
+			if response.StatusCode != http.StatusUnauthorized {
+				t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, response.StatusCode)
+			}
+			continue
+		}
+
+		if name != "allowed name" {
+			t.Logf("Check if client with %s is rejected... (expected error)", name)
+			if err == nil {
+				response.Body.Close()
+				t.Errorf("Expected client with %s to be rejected, but received status code %v", name, response.StatusCode)
+			}
+			continue
+		}
+
+		t.Logf("Check if client with %s is authenticated... (expecting user: metrics-client)", name)
+		if err != nil {
+			t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+		}
+
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK || string(body) != "metrics-client" {
+			t.Errorf("Expected status code %v and user metrics-client, but received %v and %s", http.StatusOK, response.StatusCode, body)
+		}
+
+		t.Log("Check if a token is also required when RequireToken is set... (expecting status code: 401)")
+		server.config.ClientCertificates.RequireToken = true
+		response, err = client.Get(testServer.URL)
+		if err != nil {
+			t.Fatalf("Error occured while hitting endpoint: %s", err.Error())
+		}
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expecting status code %v, but received %v", http.StatusUnauthorized, response.StatusCode)
+		}
+		server.config.ClientCertificates.RequireToken = false
+	}
+}
+
+func TestCertificateUser(t *testing.T) {
+	server := createKPIWebServer()
+	server.config = &nozzleconfiguration.NozzleConfiguration{
+		ClientCertificates: nozzleconfiguration.ClientCertificateConfiguration{
+			Enabled:      true,
+			AllowedNames: []string{"metrics-client"},
+		},
+	}
+
+	certificate := &x509.Certificate{
+		Subject:  pkix.Name{CommonName: "cell-0.example.com"},
+		DNSNames: []string{"other-client", "metrics-client"},
+	}
+	request := httptest.NewRequest("GET", "/gorouters", nil)
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{certificate}}}
+
+	t.Log("Check if the allowed name is the user of a certificate listing other names first... (expecting user: metrics-client)")
+	if user, ok := server.certificateUser(request); !ok || user != "metrics-client" {
+		t.Errorf("Expected user metrics-client, but received %s", user)
+	}
+
+	server.config.ClientCertificates.AllowedNames = nil
+
+	t.Log("Check if the first name is the user without allowed names... (expecting user: cell-0.example.com)")
+	if user, ok := server.certificateUser(request); !ok || user != "cell-0.example.com" {
+		t.Errorf("Expected user cell-0.example.com, but received %s", user)
+	}
+}
+
+func createClientCertificate(t *testing.T, commonName string, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	cert, key := createTestCertificate(t, commonName, caCert, caKey)
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+}
+
+//createTestCertificate creates a CA certificate when caCert is nil, otherwise a client certificate signed by caCert
+func createTestCertificate(t *testing.T, commonName string, caCert *x509.Certificate, caKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error generating key: %s", err.Error())
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if caCert == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+		caCert, caKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Error creating certificate: %s", err.Error())
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Error parsing certificate: %s", err.Error())
+	}
+
+	return cert, key
+}
+
+func TestSelfSignedCertificate(t *testing.T) {
+	directory, err := ioutil.TempDir("", "self-signed")
+	if err != nil {
+		t.Fatalf("Error creating temp directory: %s", err.Error())
+	}
+	defer os.RemoveAll(directory)
+
+	certPath := filepath.Join(directory, "cert.pem")
+	keyPath := filepath.Join(directory, "key.pem")
+
+	server := createKPIWebServer()
+	server.config = &nozzleconfiguration.NozzleConfiguration{}
+
+	t.Log("Check if no certificate is generated unless enabled... (expecting no cert.pem)")
+	server.ensureCertificate(certPath, keyPath)
+	if _, err := os.Stat(certPath); !os.IsNotExist(err) {
+		t.Errorf("Expected no certificate, but received %v", err)
+	}
+
+	server.config.SelfSignedCertificate.Enabled = true
+	t.Log("Check if missing certificate is generated... (expecting cert.pem for localhost)")
+	if err := server.ensureCertificate(certPath, keyPath); err != nil {
+		t.Fatalf("Expected certificate, but received error %s", err.Error())
+	}
+
+	certificate, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		t.Fatalf("Expected generated key pair, but received error %s", err.Error())
+	}
+
+	leaf, _ := x509.ParseCertificate(certificate.Certificate[0])
+	if err := leaf.VerifyHostname(defaultSelfSignedHost); err != nil {
+		t.Errorf("Expected certificate for %s, but received error %s", defaultSelfSignedHost, err.Error())
+	}
+
+	t.Log("Check if existing certificate is kept... (expecting unchanged cert.pem)")
+	server.ensureCertificate(certPath, keyPath)
+	if kept, _ := tls.LoadX509KeyPair(certPath, keyPath); string(kept.Certificate[0]) != string(certificate.Certificate[0]) {
+		t.Error("Expected existing certificate to be kept, but it was replaced")
+	}
+}
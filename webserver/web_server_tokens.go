@@ -0,0 +1,77 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webserver
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/BlueMedora/bluemedora-firehose-nozzle/webtoken"
+)
+
+//TokenCounts are the active tokens in total and per user
+type TokenCounts struct {
+	ActiveTokens           int
+	Users                  map[string]int
+	IdleTimeoutSeconds     uint32
+	AbsoluteTimeoutSeconds uint32
+}
+
+//revokeToken answers DELETE /token by invalidating the token in the token header or bearer authorization
+func (webserver *WebServer) revokeToken(w http.ResponseWriter, r *http.Request) {
+	tokenString := r.Header.Get(headerTokenKey)
+	if scheme, credentials := parseAuthorization(r); strings.EqualFold(scheme, bearerScheme) {
+		tokenString = credentials
+	}
+
+	token, ok := webserver.tokens.Revoke(tokenString)
+	if !ok {
+		webserver.logger.Debugf("Invalid token %s supplied for revocation", webtoken.HashTokenString(tokenString))
+		auditCaller(r, "", webtoken.HashTokenString(tokenString))
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, "Invalid token supplied")
+		return
+	}
+
+	webserver.forgetBasicSession(token.ID)
+	auditCaller(r, token.Username, token.ID)
+	webserver.logger.Infof("Revoked token %s of user %s", token.ID, token.Username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (webserver *WebServer) countTokens() TokenCounts {
+	counts := TokenCounts{
+		Users:                  webserver.tokens.Counts(),
+		IdleTimeoutSeconds:     webserver.config.TokenIdleTimeoutSeconds,
+		AbsoluteTimeoutSeconds: webserver.config.TokenAbsoluteTimeoutSeconds,
+	}
+
+	for _, count := range counts.Users {
+		counts.ActiveTokens += count
+	}
+
+	return counts
+}
+
+func (webserver *WebServer) tokenCountsHandler(w http.ResponseWriter, r *http.Request) {
+	webserver.logger.Debug("Received /admin/tokens request")
+
+	if !webserver.authorizeRequest(w, r) {
+		return
+	}
+
+	counts := webserver.countTokens()
+
+	messageBytes, _ := json.Marshal(counts)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(messageBytes)
+
+	if err != nil {
+		webserver.logger.Errorf("Error while answering /admin/tokens call: %s", err.Error())
+	}
+}
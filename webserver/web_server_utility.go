@@ -46,10 +46,21 @@ type Resource struct {
     IP              string
     ValueMetrics    map[string]float64
     CounterMetrics  map[string]float64
+    Tags            map[string]string `json:",omitempty"`
 }
 
-func createEnvelopeKey(envelope *events.Envelope) string {
-	return fmt.Sprintf("%s | %s | %s | %s", envelope.GetDeployment(), envelope.GetJob(), envelope.GetIndex(), envelope.GetIp())
+//CreateEnvelopeKey creates the key identifying the instance an envelope was emitted from
+func CreateEnvelopeKey(envelope *events.Envelope) string {
+	return createKey(envelope.GetDeployment(), envelope.GetJob(), envelope.GetIndex(), envelope.GetIp())
+}
+
+//CreateResourceKey creates the same key as CreateEnvelopeKey for a cached resource
+func CreateResourceKey(resource Resource) string {
+	return createKey(resource.Deployment, resource.Job, resource.Index, resource.IP)
+}
+
+func createKey(deployment string, job string, index string, ip string) string {
+	return fmt.Sprintf("%s | %s | %s | %s", deployment, job, index, ip)
 }
 
 func addMetric(envelope *events.Envelope, valueMetricMap map[string]float64, counterMetricMap map[string]float64, logger *gosteno.Logger) {
@@ -68,6 +79,44 @@ func addMetric(envelope *events.Envelope, valueMetricMap map[string]float64, cou
 	}
 }
 
+//addTags keeps the latest value of every tag the instance has sent
+func addTags(envelope *events.Envelope, resource *Resource) {
+    if len(envelope.GetTags()) == 0 {
+        return
+    }
+    
+    if resource.Tags == nil {
+        resource.Tags = make(map[string]string, len(envelope.GetTags()))
+    }
+    
+    for name, value := range envelope.GetTags() {
+        resource.Tags[name] = value
+    }
+}
+
+func copyResource(resource Resource) Resource {
+    resourceCopy := resource
+    resourceCopy.ValueMetrics = make(map[string]float64, len(resource.ValueMetrics))
+    resourceCopy.CounterMetrics = make(map[string]float64, len(resource.CounterMetrics))
+    
+    for name, value := range resource.ValueMetrics {
+        resourceCopy.ValueMetrics[name] = value
+    }
+    
+    for name, value := range resource.CounterMetrics {
+        resourceCopy.CounterMetrics[name] = value
+    }
+    
+    if resource.Tags != nil {
+        resourceCopy.Tags = make(map[string]string, len(resource.Tags))
+        for name, value := range resource.Tags {
+            resourceCopy.Tags[name] = value
+        }
+    }
+    
+    return resourceCopy
+}
+
 func getValues(resourceMap map[string]Resource) []Resource {
     resources := make([]Resource, 0, len(resourceMap))
     
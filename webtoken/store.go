@@ -0,0 +1,168 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webtoken
+
+import (
+    "container/heap"
+    "sync"
+    "time"
+)
+
+//Clock returns the current time of a Store
+type Clock func() time.Time
+
+//Store keeps tokens by the hash of their token string. Expired tokens are removed from a single expiry heap
+//whenever the store is accessed, so no goroutine or timer is needed per token
+type Store struct {
+    mutex           sync.Mutex
+    clock           Clock
+    idleTimeout     time.Duration
+    absoluteTimeout time.Duration
+    timeoutCallback TokenTimeout
+    tokens          map[string]*Token //Maps token ID to token
+    expiries        expiryHeap
+}
+
+//NewStore creates a Store whose tokens time out when they are not used for idleTimeout or, if absoluteTimeout
+//is not zero, absoluteTimeout after creation. clock defaults to time.Now, timeoutCallback is optional
+func NewStore(idleTimeout time.Duration, absoluteTimeout time.Duration, clock Clock, timeoutCallback TokenTimeout) *Store {
+    if clock == nil {
+        clock = time.Now
+    }
+    
+    return &Store {
+        clock:              clock,
+        idleTimeout:        idleTimeout,
+        absoluteTimeout:    absoluteTimeout,
+        timeoutCallback:    timeoutCallback,
+        tokens:             make(map[string]*Token),
+    }
+}
+
+//Create issues a token for username and returns it with its token string
+func (store *Store) Create(username string) (Token, string, error) {
+    tokenString, err := GenerateTokenString()
+    if err != nil {
+        return Token{}, "", err
+    }
+    
+    store.mutex.Lock()
+    now := store.clock()
+    expired := store.expire(now)
+    
+    token := &Token {
+        ID:         HashTokenString(tokenString),
+        Username:   username,
+        CreatedAt:  now,
+        LastUsed:   now,
+    }
+    token.ExpiresAt = token.expiry(store.idleTimeout, store.absoluteTimeout)
+    
+    store.tokens[token.ID] = token
+    heap.Push(&store.expiries, token)
+    created := *token
+    store.mutex.Unlock()
+    
+    store.notify(expired)
+    return created, tokenString, nil
+}
+
+//Use marks the token of tokenString as used, which extends its idle timeout
+func (store *Store) Use(tokenString string) (Token, error) {
+    return store.UseID(HashTokenString(tokenString))
+}
+
+//UseID marks the token with tokenID as used, for sessions whose token string was never handed out
+func (store *Store) UseID(tokenID string) (Token, error) {
+    store.mutex.Lock()
+    now := store.clock()
+    expired := store.expire(now)
+    
+    token, ok := store.tokens[tokenID]
+    if !ok {
+        store.mutex.Unlock()
+        store.notify(expired)
+        return Token{}, &InvalidTokenError{"Attempt to use invalid token"}
+    }
+    
+    token.LastUsed = now
+    token.ExpiresAt = token.expiry(store.idleTimeout, store.absoluteTimeout)
+    heap.Fix(&store.expiries, token.index)
+    used := *token
+    store.mutex.Unlock()
+    
+    store.notify(expired)
+    return used, nil
+}
+
+//Revoke removes the token of tokenString, it returns false if there was no valid token
+func (store *Store) Revoke(tokenString string) (Token, bool) {
+    store.mutex.Lock()
+    expired := store.expire(store.clock())
+    
+    token, ok := store.tokens[HashTokenString(tokenString)]
+    var revoked Token
+    if ok {
+        store.remove(token)
+        revoked = *token
+    }
+    store.mutex.Unlock()
+    
+    store.notify(expired)
+    return revoked, ok
+}
+
+//Counts returns the number of valid tokens per user
+func (store *Store) Counts() map[string]int {
+    store.mutex.Lock()
+    expired := store.expire(store.clock())
+    
+    counts := make(map[string]int)
+    for _, token := range store.tokens {
+        counts[token.Username]++
+    }
+    store.mutex.Unlock()
+    
+    store.notify(expired)
+    return counts
+}
+
+//Expire removes the tokens that timed out, it is called by every other method but can be called to release
+//tokens of a store that is not accessed
+func (store *Store) Expire() {
+    store.mutex.Lock()
+    expired := store.expire(store.clock())
+    store.mutex.Unlock()
+    
+    store.notify(expired)
+}
+
+//expire must be called with the mutex held
+func (store *Store) expire(now time.Time) []*Token {
+    var expired []*Token
+    for len(store.expiries) > 0 && !now.Before(store.expiries[0].ExpiresAt) {
+        token := store.expiries[0]
+        store.remove(token)
+        expired = append(expired, token)
+    }
+    
+    return expired
+}
+
+//remove must be called with the mutex held
+func (store *Store) remove(token *Token) {
+    heap.Remove(&store.expiries, token.index)
+    delete(store.tokens, token.ID)
+}
+
+//notify calls the timeout callback without the mutex held so that it may use the store
+func (store *Store) notify(expired []*Token) {
+    if store.timeoutCallback == nil {
+        return
+    }
+    
+    for _, token := range expired {
+        store.timeoutCallback(token)
+    }
+}
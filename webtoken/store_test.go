@@ -0,0 +1,148 @@
+// Copyright (c) 2016 Blue Medora, Inc. All rights reserved.
+// This file is subject to the terms and conditions defined in the included file 'LICENSE.txt'.
+
+package webtoken
+
+import (
+    "strings"
+    "testing"
+    "time"
+)
+
+type testClock struct {
+    now time.Time
+}
+
+func (clock *testClock) Now() time.Time {
+    return clock.now
+}
+
+func (clock *testClock) Advance(duration time.Duration) {
+    clock.now = clock.now.Add(duration)
+}
+
+func TestToken(t *testing.T) {
+    clock := &testClock{now: time.Unix(1000, 0)}
+    var timedOut []string
+    store := NewStore(time.Minute, 0, clock.Now, func(token *Token) { timedOut = append(timedOut, token.ID) })
+    
+    t.Log("Creating token")
+    token, tokenString, err := store.Create("user")
+    if err != nil {
+        t.Fatalf("Expected token, but received error %s", err.Error())
+    }
+    t.Log("Token Created")
+    
+    t.Log("Checking if token is identified by the hash of its string...")
+    if token.ID != HashTokenString(tokenString) || token.ID == tokenString {
+        t.Errorf("Expected token ID %s, but received %s", HashTokenString(tokenString), token.ID)
+    }
+    
+    t.Log("Checking if token is useable within the idle timeout... (expected no-error)")
+    for i := 0; i < 3; i++ {
+        clock.Advance(50 * time.Second)
+        if _, err = store.Use(tokenString); err != nil {
+            t.Fatalf("Expected token useable, but was un-useable after %d uses", i)
+        }
+    }
+    
+    t.Log("Checking if token is useable by its ID... (expected no-error)")
+    if _, err = store.UseID(token.ID); err != nil {
+        t.Fatalf("Expected token useable by ID, but was un-useable")
+    }
+    
+    t.Log("Passing the idle timeout...")
+    clock.Advance(time.Minute)
+    
+    t.Log("Checking if token is useable after timeout... (expected error)")
+    _, err = store.Use(tokenString)
+    if err == nil {
+        t.Fatalf("Expected token un-useable, but was useable")
+    }
+    
+    t.Log("Checking if InvalidTokenError... (expected string to contain: Invalid Token Error:)")
+    if !strings.Contains(err.Error(), "Invalid Token Error:") {
+        t.Errorf("Expected error string to contain 'Invalid Token Error:', but received %s", err.Error())
+    }
+    
+    t.Log("Checking if timeout callback was called once...")
+    if len(timedOut) != 1 || timedOut[0] != token.ID {
+        t.Errorf("Expected timeout of token %s, but received %v", token.ID, timedOut)
+    }
+}
+
+func TestTokenAbsoluteTimeout(t *testing.T) {
+    clock := &testClock{now: time.Unix(1000, 0)}
+    store := NewStore(time.Minute, 2 * time.Minute, clock.Now, nil)
+    
+    t.Log("Creating token with a 2 minute absolute timeout")
+    _, tokenString, _ := store.Create("user")
+    
+    t.Log("Using token until the absolute timeout passes...")
+    for i := 0; i < 3; i++ {
+        clock.Advance(50 * time.Second)
+        _, err := store.Use(tokenString)
+        
+        expectValid := i < 2
+        if (err == nil) != expectValid {
+            t.Errorf("Expected token useable %v after %v, but received error %v", expectValid, time.Duration(i + 1) * 50 * time.Second, err)
+        }
+    }
+}
+
+func TestTokenRevoke(t *testing.T) {
+    store := NewStore(time.Minute, 0, nil, nil)
+    
+    t.Log("Creating tokens")
+    token, tokenString, _ := store.Create("user")
+    _, otherTokenString, _ := store.Create("user")
+    
+    t.Log("Revoking token twice... (expected true, then false)")
+    if revoked, ok := store.Revoke(tokenString); !ok || revoked.ID != token.ID {
+        t.Errorf("Expected revoke of token %s, but received %v", token.ID, ok)
+    }
+    
+    if _, ok := store.Revoke(tokenString); ok {
+        t.Errorf("Expected second revoke to fail, but received true")
+    }
+    
+    t.Log("Checking if token is useable after revoke... (expected error)")
+    if _, err := store.Use(tokenString); err == nil {
+        t.Errorf("Expected token un-useable, but was useable")
+    }
+    
+    t.Log("Checking if other token is still useable... (expected no-error)")
+    if _, err := store.Use(otherTokenString); err != nil {
+        t.Errorf("Expected token useable, but received error %s", err.Error())
+    }
+}
+
+func TestTokenCounts(t *testing.T) {
+    clock := &testClock{now: time.Unix(1000, 0)}
+    store := NewStore(time.Minute, 0, clock.Now, nil)
+    
+    t.Log("Creating two tokens of user one and one of user two")
+    store.Create("one")
+    clock.Advance(30 * time.Second)
+    store.Create("one")
+    store.Create("two")
+    
+    counts := store.Counts()
+    if counts["one"] != 2 || counts["two"] != 1 {
+        t.Errorf("Expected counts map[one:2 two:1], but received %v", counts)
+    }
+    
+    t.Log("Checking counts after the first token expired...")
+    clock.Advance(40 * time.Second)
+    counts = store.Counts()
+    if counts["one"] != 1 || counts["two"] != 1 {
+        t.Errorf("Expected counts map[one:1 two:1], but received %v", counts)
+    }
+    
+    t.Log("Checking counts after all tokens expired...")
+    clock.Advance(time.Minute)
+    store.Expire()
+    if counts = store.Counts(); len(counts) != 0 {
+        t.Errorf("Expected no counts, but received %v", counts)
+    }
+}
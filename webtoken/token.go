@@ -5,11 +5,6 @@ package webtoken
 
 import (
     "time"
-    "sync"
-)
-
-var (
-    tokenTimeout = 60
 )
 
 //TokenTimeout callback when a token times out
@@ -24,64 +19,52 @@ func (e *InvalidTokenError) Error() string {
     return "Invalid Token Error: " + e.s
 }
 
-//Token token used for webserver communication
+//Token token used for webserver communication, the token string itself is only handed out once.
+//Tokens are owned by a Store which hands out copies
 type Token struct {
-    TokenValue                  string
-    validToken                  bool
-    tokenUsedSinceLastTimout    bool
-    timoutTicker                *time.Ticker
-    mux                         sync.Mutex
+    ID          string //Hash of the token string
+    Username    string
+    CreatedAt   time.Time
+    LastUsed    time.Time
+    ExpiresAt   time.Time
+    index       int //Position in the expiry heap of the store
 }
 
-//New creates a new token
-func New(timeoutCallback TokenTimeout) *Token {
-    newToken := Token {
-        TokenValue:                 GenerateTokenString(),
-        validToken:                 true,
-        tokenUsedSinceLastTimout:   false,
-        timoutTicker:               time.NewTicker(time.Duration(tokenTimeout) * time.Second),
+//expiry is the earlier of the idle and absolute timeouts
+func (token *Token) expiry(idleTimeout time.Duration, absoluteTimeout time.Duration) time.Time {
+    expiresAt := token.LastUsed.Add(idleTimeout)
+    if absoluteTimeout > 0 {
+        absoluteExpiry := token.CreatedAt.Add(absoluteTimeout)
+        if absoluteExpiry.Before(expiresAt) {
+            expiresAt = absoluteExpiry
+        }
     }
     
-    go newToken.startTimeout(timeoutCallback)
-    
-    return &newToken
+    return expiresAt
 }
 
-func (token *Token) startTimeout(timeoutCallback TokenTimeout) {
-    for {
-        select {
-            case <-token.timoutTicker.C:
-                token.mux.Lock()
-                if !token.tokenUsedSinceLastTimout {
-                    token.validToken = false
-                    token.mux.Unlock()
-                    defer timeoutCallback(token)
-                    return
-                }
-                
-                token.tokenUsedSinceLastTimout = false;
-                token.mux.Unlock()
-        }
-    }
+//expiryHeap orders tokens by ExpiresAt so only the next expiring token has to be looked at
+type expiryHeap []*Token
+
+func (h expiryHeap) Len() int { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].ExpiresAt.Before(h[j].ExpiresAt) }
+func (h expiryHeap) Swap(i, j int) {
+    h[i], h[j] = h[j], h[i]
+    h[i].index = i
+    h[j].index = j
 }
 
-//IsTokenValid validity of token
-func (token *Token) IsTokenValid() bool {
-    token.mux.Lock()
-    defer token.mux.Unlock()
-    return token.validToken
+func (h *expiryHeap) Push(x interface{}) {
+    token := x.(*Token)
+    token.index = len(*h)
+    *h = append(*h, token)
 }
 
-//UseToken marks token as used 
-func (token *Token) UseToken() error {
-    token.mux.Lock()
-    defer token.mux.Unlock()
-    
-    if token.validToken {
-        token.tokenUsedSinceLastTimout = true
-    } else {
-        return &InvalidTokenError{"Attempt to use invalid token"}
-    }
-    
-    return nil
-}
\ No newline at end of file
+func (h *expiryHeap) Pop() interface{} {
+    old := *h
+    token := old[len(old) - 1]
+    old[len(old) - 1] = nil
+    token.index = -1
+    *h = old[:len(old) - 1]
+    return token
+}
@@ -4,20 +4,34 @@
 package webtoken
 
 import (
-    "math/rand"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/hex"
+    "math/big"
 )
 
 var (
-    tokenLength = 15
+    tokenLength = 32
     tokenRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890")
 )
 
-//GenerateTokenString creates a token string to be used in webserver
-func GenerateTokenString() string {
+//GenerateTokenString creates a token string to be used in webserver from a cryptographically secure source
+func GenerateTokenString() (string, error) {
+    max := big.NewInt(int64(len(tokenRunes)))
     token := make([]rune, tokenLength)
     for i:= range token {
-        token[i] = tokenRunes[rand.Intn(len(tokenRunes))]
+        index, err := rand.Int(rand.Reader, max)
+        if err != nil {
+            return "", err
+        }
+        token[i] = tokenRunes[index.Int64()]
     }
     
-    return string(token)
+    return string(token), nil
+}
+
+//HashTokenString hashes a token string, tokens are only stored and logged by their hash
+func HashTokenString(tokenString string) string {
+    sum := sha256.Sum256([]byte(tokenString))
+    return hex.EncodeToString(sum[:])
 }
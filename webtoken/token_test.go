@@ -6,15 +6,17 @@ package webtoken
 import (
     "testing"
     "time"
-    "strings"
 )
 
 func TestTokenStringUniqueness(t *testing.T) {
     t.Log("Generating first token string...")
-    tokenStringOne := GenerateTokenString()
+    tokenStringOne, err := GenerateTokenString()
+    if err != nil {
+        t.Fatalf("Expected token string, but received error %s", err.Error())
+    }
     
     t.Log("Generating second token string...")
-    tokenStringTwo := GenerateTokenString()
+    tokenStringTwo, _ := GenerateTokenString()
     
     t.Log("Checking that first and second token strings are not equal...")
     if tokenStringOne == tokenStringTwo {
@@ -22,42 +24,20 @@ func TestTokenStringUniqueness(t *testing.T) {
     }
 }
 
-func TestToken(t *testing.T) {
-    t.Log("Creating token")
-    token := New(tokenTimeoutCallback)
-    t.Log("Token Created")
-    
-    t.Log("Checking if token is initially valid... (expected value: true)")
-    if !token.IsTokenValid() {
-        t.Fatalf("Expected is token valid of true, but received false")
-    }
-    
-    t.Log("Checking if token is intially useable... (expected no-error)")
-    err := token.UseToken()
-    if err != nil {
-        t.Fatalf("Expected token useable, but was un-useable")
+func TestTokenExpiry(t *testing.T) {
+    created := time.Unix(1000, 0)
+    token := Token {
+        CreatedAt:  created,
+        LastUsed:   created.Add(50 * time.Minute),
     }
     
-    t.Log("Waiting 3 minutes to ensure token invalidates...")
-    time.Sleep(time.Duration(3) * time.Minute)
-    
-    t.Log("Checking if token is invalid after timeout... (expected value: false)")
-    if token.IsTokenValid() {
-        t.Fatalf("Expected is token valid of false, but received true")
+    t.Log("Checking idle expiry without absolute timeout...")
+    if expiry := token.expiry(time.Minute, 0); !expiry.Equal(created.Add(51 * time.Minute)) {
+        t.Errorf("Expected expiry %v, but received %v", created.Add(51 * time.Minute), expiry)
     }
     
-    t.Log("Checking if token is useable after timeout... (expected error)")
-    err = token.UseToken()
-    if err == nil {
-        t.Fatalf("Expected token un-useable, received error")
-    }
-    
-    t.Log("Checking if InvalidTokenError... (expected string to contain: Invalid Token Error:)")
-    if !strings.Contains(err.Error(), "Invalid Token Error:") {
-        t.Errorf("Expected error string to contain 'Invalid Token Error:', but received %s", err.Error())
+    t.Log("Checking absolute timeout limits the idle expiry...")
+    if expiry := token.expiry(time.Minute, 50 * time.Minute + 30 * time.Second); !expiry.Equal(created.Add(50 * time.Minute + 30 * time.Second)) {
+        t.Errorf("Expected expiry %v, but received %v", created.Add(50 * time.Minute + 30 * time.Second), expiry)
     }
 }
-
-func tokenTimeoutCallback(token *Token) {
-    //No logic just need to pass in for test
-}
\ No newline at end of file